@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 
 	"github.com/Sheep42/Monkey-Lang/token"
@@ -10,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Pos // position of the first character belonging to the node
+	End() token.Pos // position of the first character immediately after the node
 }
 
 type Statement interface {
@@ -22,6 +25,13 @@ type Expression interface {
 	expressionNode()
 }
 
+// Pattern is the left side of a match arm. Matching a pattern against a value
+// may bind names, which is why identifiers are patterns too.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
 type Program struct {
 	Statements []Statement
 }
@@ -34,6 +44,22 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.NoPos
+}
+
+func (p *Program) End() token.Pos {
+	if n := len(p.Statements); n > 0 {
+		return p.Statements[n-1].End()
+	}
+
+	return token.NoPos
+}
+
 func (p *Program) String() string {
 
 	var out bytes.Buffer
@@ -51,19 +77,40 @@ func (p *Program) String() string {
 // Statements
 
 type LetStatement struct {
-	Token token.Token //The token.LET token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token //The token.LET token
+	Name    *Identifier
+	Pattern Pattern // set instead of Name when destructuring: let [a, b] = arr
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Pos       { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Pos {
+
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+
+	if ls.Pattern != nil {
+		return ls.Pattern.End()
+	}
+
+	return ls.Name.End()
+
+}
 func (ls *LetStatement) String() string {
 
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
+
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -85,6 +132,16 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Pos       { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Pos {
+
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+
+	return rs.Token.End
+
+}
 func (rs *ReturnStatement) String() string {
 
 	var out bytes.Buffer
@@ -103,6 +160,100 @@ func (rs *ReturnStatement) String() string {
 
 }
 
+type WhileStatement struct {
+	Token     token.Token // The token.WHILE token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Pos       { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Pos {
+
+	if ws.Body != nil {
+		return ws.Body.End()
+	}
+
+	return ws.Token.End
+
+}
+func (ws *WhileStatement) String() string {
+
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+
+}
+
+// ForStatement loops over the elements of an iterable: for (x in arr) { ... }.
+// With two names, for (k, v in hash) { ... }, Key is bound to the index or
+// hash key. A single name over a hash is bound to the keys.
+type ForStatement struct {
+	Token    token.Token // The token.FOR token
+	Key      *Identifier // nil unless two names are given
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Pos       { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Pos {
+
+	if fs.Body != nil {
+		return fs.Body.End()
+	}
+
+	return fs.Token.End
+
+}
+func (fs *ForStatement) String() string {
+
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+
+}
+
+type BreakStatement struct {
+	Token token.Token // The token.BREAK token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Pos       { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Pos       { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+type ContinueStatement struct {
+	Token token.Token // The token.CONTINUE token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Pos       { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Pos       { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
 type ExpressionStatement struct {
 	Token      token.Token // first token in the expression
 	Expression Expression
@@ -110,6 +261,16 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Pos       { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Pos {
+
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+
+	return es.Token.End
+
+}
 func (es *ExpressionStatement) String() string {
 
 	if es.Expression != nil {
@@ -123,12 +284,27 @@ func (es *ExpressionStatement) String() string {
 }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // '{'
 	Statements []Statement
+	Rbrace     token.Pos // position of the closing '}'
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Pos       { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Pos {
+
+	if bs.Rbrace.IsValid() {
+		return bs.Rbrace + 1
+	}
+
+	if n := len(bs.Statements); n > 0 {
+		return bs.Statements[n-1].End()
+	}
+
+	return bs.Token.End
+
+}
 func (bs *BlockStatement) String() string {
 
 	var out bytes.Buffer
@@ -143,6 +319,20 @@ func (bs *BlockStatement) String() string {
 
 }
 
+// BadStatement is a placeholder for a statement that could not be parsed.
+// It keeps the partial AST free of nils.
+type BadStatement struct {
+	Token token.Token // the token the statement started with
+	From  token.Pos
+	To    token.Pos
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) Pos() token.Pos       { return bs.From }
+func (bs *BadStatement) End() token.Pos       { return bs.To }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
 // Expressions
 
 type Identifier struct {
@@ -151,18 +341,47 @@ type Identifier struct {
 }
 
 func (i *Identifier) expressionNode()      {}
+func (i *Identifier) patternNode()         {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Pos       { return i.Token.Pos }
+func (i *Identifier) End() token.Pos       { return i.Token.End }
 func (i *Identifier) String() string       { return i.Value }
 
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // set instead of Value when the literal doesn't fit in an int64
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Pos       { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Pos       { return il.Token.End }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Pos       { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Pos       { return fl.Token.End }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+// RationalLiteral is an exact fraction written with an r suffix, e.g. 0.1r
+type RationalLiteral struct {
+	Token token.Token
+	Value *big.Rat
+}
+
+func (rl *RationalLiteral) expressionNode()      {}
+func (rl *RationalLiteral) TokenLiteral() string { return rl.Token.Literal }
+func (rl *RationalLiteral) Pos() token.Pos       { return rl.Token.Pos }
+func (rl *RationalLiteral) End() token.Pos       { return rl.Token.End }
+func (rl *RationalLiteral) String() string       { return rl.Token.Literal }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -170,15 +389,20 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Pos       { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Pos       { return sl.Token.End }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
 type ArrayLiteral struct {
 	Token    token.Token // '['
 	Elements []Expression
+	Rbrack   token.Pos // position of the closing ']'
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Pos       { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Pos       { return closingEnd(al.Rbrack, al.Token.End) }
 func (al *ArrayLiteral) String() string {
 
 	var out bytes.Buffer
@@ -198,19 +422,28 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // '['
-	Left  Expression
-	Index Expression
+	Token    token.Token // '['
+	Left     Expression
+	Index    Expression
+	Rbrack   token.Pos // position of the closing ']'
+	Optional bool      // written as Left?.[Index], null if Left is null
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Pos       { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Pos       { return closingEnd(ie.Rbrack, ie.Token.End) }
 func (ie *IndexExpression) String() string {
 
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+
+	if ie.Optional {
+		out.WriteString("?.")
+	}
+
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -219,6 +452,29 @@ func (ie *IndexExpression) String() string {
 
 }
 
+// MemberExpression is Object.Property, which reads the "Property" key of a
+// hash. As the function of a call it is a method call instead: arr.push(4)
+type MemberExpression struct {
+	Token    token.Token // the token.DOT or token.OPTIONAL_CHAIN token
+	Object   Expression
+	Property *Identifier
+	Optional bool // written as Object?.Property, null if Object is null
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Pos       { return me.Object.Pos() }
+func (me *MemberExpression) End() token.Pos       { return me.Property.End() }
+func (me *MemberExpression) String() string {
+
+	if me.Optional {
+		return me.Object.String() + "?." + me.Property.String()
+	}
+
+	return me.Object.String() + "." + me.Property.String()
+
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -227,6 +483,16 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Pos       { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Pos {
+
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+
+	return pe.Token.End
+
+}
 func (pe *PrefixExpression) String() string {
 
 	var out bytes.Buffer
@@ -240,6 +506,69 @@ func (pe *PrefixExpression) String() string {
 
 }
 
+// AssignExpression updates an existing binding or an element of an array or
+// hash: x = 1, arr[0] = 1, h["k"] += 1
+type AssignExpression struct {
+	Token    token.Token // the assignment operator
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Pos       { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Pos {
+
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+
+	return ae.Token.End
+
+}
+func (ae *AssignExpression) String() string {
+
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+
+}
+
+// ConditionalExpression is the ternary Condition ? Consequence : Alternative
+type ConditionalExpression struct {
+	Token       token.Token // '?'
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) Pos() token.Pos       { return ce.Condition.Pos() }
+func (ce *ConditionalExpression) End() token.Pos       { return ce.Alternative.End() }
+func (ce *ConditionalExpression) String() string {
+
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ce.Condition.String())
+	out.WriteString(" ? ")
+	out.WriteString(ce.Consequence.String())
+	out.WriteString(" : ")
+	out.WriteString(ce.Alternative.String())
+	out.WriteString(")")
+
+	return out.String()
+
+}
+
 type InfixExpression struct {
 	Token    token.Token
 	Left     Expression
@@ -249,6 +578,16 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Pos       { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Pos {
+
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+
+	return ie.Token.End
+
+}
 func (ie *InfixExpression) String() string {
 
 	var out bytes.Buffer
@@ -270,15 +609,20 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Pos       { return b.Token.Pos }
+func (b *Boolean) End() token.Pos       { return b.Token.End }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type HashLiteral struct {
-	Token token.Token //'{'
-	Pairs map[Expression]Expression
+	Token  token.Token //'{'
+	Pairs  map[Expression]Expression
+	Rbrace token.Pos // position of the closing '}'
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Pos       { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Pos       { return closingEnd(hl.Rbrace, hl.Token.End) }
 func (hl *HashLiteral) String() string {
 
 	var out bytes.Buffer
@@ -301,10 +645,29 @@ type IfExpression struct {
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
+	ElseIf      *IfExpression // set instead of Alternative for an else if
 }
 
 func (ife *IfExpression) expressionNode()      {}
 func (ife *IfExpression) TokenLiteral() string { return ife.Token.Literal }
+func (ife *IfExpression) Pos() token.Pos       { return ife.Token.Pos }
+func (ife *IfExpression) End() token.Pos {
+
+	if ife.ElseIf != nil {
+		return ife.ElseIf.End()
+	}
+
+	if ife.Alternative != nil {
+		return ife.Alternative.End()
+	}
+
+	if ife.Consequence != nil {
+		return ife.Consequence.End()
+	}
+
+	return ife.Token.End
+
+}
 func (ife *IfExpression) String() string {
 
 	var out bytes.Buffer
@@ -314,6 +677,11 @@ func (ife *IfExpression) String() string {
 	out.WriteString(" ")
 	out.WriteString(ife.Consequence.String())
 
+	if ife.ElseIf != nil {
+		out.WriteString("else ")
+		out.WriteString(ife.ElseIf.String())
+	}
+
 	if ife.Alternative != nil {
 		out.WriteString("else ")
 		out.WriteString(ife.Alternative.String())
@@ -325,12 +693,23 @@ func (ife *IfExpression) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token
-	Parameters []*Identifier
+	Name       string    // the name it is bound to by let, if any
+	Parameters []Pattern // identifiers or patterns, optionally with defaults, and a final *RestPattern
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Pos       { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Pos {
+
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+
+	return fl.Token.End
+
+}
 func (fl *FunctionLiteral) String() string {
 
 	var out bytes.Buffer
@@ -351,13 +730,17 @@ func (fl *FunctionLiteral) String() string {
 }
 
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // '('
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Pos // position of the closing ')'
+	Optional  bool      // written as Function?.(Arguments), null if Function is null
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Pos       { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Pos       { return closingEnd(ce.Rparen, ce.Token.End) }
 func (ce *CallExpression) String() string {
 
 	var out bytes.Buffer
@@ -368,6 +751,11 @@ func (ce *CallExpression) String() string {
 	}
 
 	out.WriteString(ce.Function.String())
+
+	if ce.Optional {
+		out.WriteString("?.")
+	}
+
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -375,3 +763,237 @@ func (ce *CallExpression) String() string {
 	return out.String()
 
 }
+
+// KeywordArgument passes Value to the parameter called Name: f(limit: 10). It
+// only appears in CallExpression.Arguments, after any positional arguments.
+type KeywordArgument struct {
+	Token token.Token // the name's token.IDENT token
+	Name  *Identifier
+	Value Expression
+}
+
+func (ka *KeywordArgument) expressionNode()      {}
+func (ka *KeywordArgument) TokenLiteral() string { return ka.Token.Literal }
+func (ka *KeywordArgument) Pos() token.Pos       { return ka.Token.Pos }
+func (ka *KeywordArgument) End() token.Pos       { return ka.Value.End() }
+func (ka *KeywordArgument) String() string {
+	return ka.Name.String() + ": " + ka.Value.String()
+}
+
+// MatchExpression picks the first arm whose pattern matches Subject:
+// match (x) { [a, ...rest] => a, _ => 0 }
+type MatchExpression struct {
+	Token   token.Token // The token.MATCH token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Pos
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Pos       { return me.Token.Pos }
+func (me *MatchExpression) End() token.Pos       { return closingEnd(me.Rbrace, me.Token.End) }
+func (me *MatchExpression) String() string {
+
+	var out bytes.Buffer
+
+	arms := []string{}
+
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+
+}
+
+// MatchArm is a single pattern => body arm. Body is an Expression, or a
+// *BlockStatement when the arm is written with braces.
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // nil unless the arm has an if guard
+	Body    Node
+}
+
+func (ma *MatchArm) String() string {
+
+	var out bytes.Buffer
+
+	out.WriteString(ma.Pattern.String())
+
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+
+}
+
+// LiteralPattern matches values equal to a literal, using hash key equality:
+// 1, "str", true, -2.5
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) Pos() token.Pos       { return lp.Value.Pos() }
+func (lp *LiteralPattern) End() token.Pos       { return lp.Value.End() }
+func (lp *LiteralPattern) String() string       { return lp.Value.String() }
+
+// ArrayPattern matches arrays element by element. Without a Rest the array
+// must have exactly as many elements as the pattern.
+type ArrayPattern struct {
+	Token    token.Token // '['
+	Elements []Pattern
+	Rest     *RestPattern // nil unless the pattern ends with ...
+	Rbrack   token.Pos
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Pos       { return ap.Token.Pos }
+func (ap *ArrayPattern) End() token.Pos       { return closingEnd(ap.Rbrack, ap.Token.End) }
+func (ap *ArrayPattern) String() string {
+
+	var out bytes.Buffer
+
+	elements := []string{}
+
+	for _, e := range ap.Elements {
+		elements = append(elements, e.String())
+	}
+
+	if ap.Rest != nil {
+		elements = append(elements, ap.Rest.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+
+}
+
+// RestPattern matches the remaining elements of an array, binding them to Name
+// as a new array unless it is a bare ...
+type RestPattern struct {
+	Token token.Token // The token.ELLIPSIS token
+	Name  *Identifier // nil for a bare ...
+}
+
+func (rp *RestPattern) patternNode()         {}
+func (rp *RestPattern) TokenLiteral() string { return rp.Token.Literal }
+func (rp *RestPattern) Pos() token.Pos       { return rp.Token.Pos }
+func (rp *RestPattern) End() token.Pos {
+
+	if rp.Name != nil {
+		return rp.Name.End()
+	}
+
+	return rp.Token.End
+
+}
+func (rp *RestPattern) String() string {
+
+	if rp.Name != nil {
+		return "..." + rp.Name.String()
+	}
+
+	return "..."
+
+}
+
+// HashPattern matches hashes that contain every listed key with a value
+// matching its pattern. Other keys are ignored. An identifier key stands for
+// the string of the same name, and on its own also binds that name:
+// {name, age: years}
+type HashPattern struct {
+	Token  token.Token // '{'
+	Pairs  []*HashPatternPair
+	Rbrace token.Pos
+}
+
+type HashPatternPair struct {
+	Key       Expression // a literal key
+	Value     Pattern
+	Shorthand bool // written as just the name, Value binds the key
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Pos       { return hp.Token.Pos }
+func (hp *HashPattern) End() token.Pos       { return closingEnd(hp.Rbrace, hp.Token.End) }
+func (hp *HashPattern) String() string {
+
+	var out bytes.Buffer
+
+	pairs := []string{}
+
+	for _, pair := range hp.Pairs {
+		if pair.Shorthand {
+			pairs = append(pairs, pair.Value.String())
+		} else {
+			pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+		}
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+
+}
+
+// DefaultPattern is an array element or hash value pattern with a fallback,
+// used when the element or key is missing: [a, b = 2]
+type DefaultPattern struct {
+	Pattern Pattern
+	Default Expression
+}
+
+func (dp *DefaultPattern) patternNode()         {}
+func (dp *DefaultPattern) TokenLiteral() string { return dp.Pattern.TokenLiteral() }
+func (dp *DefaultPattern) Pos() token.Pos       { return dp.Pattern.Pos() }
+func (dp *DefaultPattern) End() token.Pos       { return dp.Default.End() }
+func (dp *DefaultPattern) String() string {
+	return dp.Pattern.String() + " = " + dp.Default.String()
+}
+
+// BadExpression is a placeholder for an expression that could not be parsed.
+// It keeps the partial AST free of nils.
+type BadExpression struct {
+	Token token.Token // the token where parsing failed
+	From  token.Pos
+	To    token.Pos
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) Pos() token.Pos       { return be.From }
+func (be *BadExpression) End() token.Pos       { return be.To }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
+// closingEnd returns the end of a node terminated by a closing delimiter,
+// falling back to the end of its opening token if the delimiter is missing
+func closingEnd(closing token.Pos, fallback token.Pos) token.Pos {
+
+	if closing.IsValid() {
+		return closing + 1
+	}
+
+	return fallback
+
+}
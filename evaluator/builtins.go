@@ -2,6 +2,11 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/Sheep42/Monkey-Lang/object"
 )
@@ -18,11 +23,14 @@ var builtins = map[string]*object.Builtin{
 			switch arg := args[0].(type) {
 
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 
+			case *object.Range:
+				return &object.Integer{Value: arg.Len()}
+
 			default:
 				return newError(fmt.Sprintf("len: Unsupported argument. expected=STRING. got=%s", arg.Type()))
 
@@ -96,7 +104,10 @@ var builtins = map[string]*object.Builtin{
 
 		},
 	},
+	// push returns a new array and leaves its argument untouched, unlike
+	// index assignment which updates an array in place
 	"push": {
+		Params: []string{"array", "value"},
 		Fn: func(args ...object.Object) object.Object {
 
 			if len(args) != 2 {
@@ -118,6 +129,203 @@ var builtins = map[string]*object.Builtin{
 
 		},
 	},
+	"bytes": {
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) != 1 {
+				return newError("bytes: Got wrong number of args. Expected=%d. Got=%d", 1, len(args))
+			}
+
+			if args[0].Type() != object.StringObj {
+				return newError("bytes: No implementation for argument type %T. Expected=%s", args[0], object.StringObj)
+			}
+
+			str := args[0].(*object.String).Value
+			elements := make([]object.Object, len(str))
+
+			for i := 0; i < len(str); i++ {
+				elements[i] = &object.Integer{Value: int64(str[i])}
+			}
+
+			return &object.Array{Elements: elements}
+
+		},
+	},
+	"float": {
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) != 1 {
+				return newError("float: Got wrong number of args. Expected=%d. Got=%d", 1, len(args))
+			}
+
+			switch arg := args[0].(type) {
+
+			case *object.Float:
+				return arg
+
+			case *object.Integer, *object.BigInteger, *object.Rational:
+				return &object.Float{Value: toFloat(arg)}
+
+			case *object.String:
+				val, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+
+				if err != nil {
+					return newError("float: Could not parse %q as float", arg.Value)
+				}
+
+				return &object.Float{Value: val}
+
+			default:
+				return newError("float: No implementation for argument type %T. Expected=%s, %s or %s", arg, object.IntegerObj, object.FloatObj, object.StringObj)
+
+			}
+
+		},
+	},
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) != 1 {
+				return newError("int: Got wrong number of args. Expected=%d. Got=%d", 1, len(args))
+			}
+
+			switch arg := args[0].(type) {
+
+			case *object.Integer, *object.BigInteger:
+				return arg
+
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("int: Cannot convert %s to an integer", arg.Inspect())
+				}
+
+				// truncates toward zero, like a Go conversion
+				val, _ := big.NewFloat(arg.Value).Int(nil)
+				return normalizeInteger(val)
+
+			case *object.Rational:
+				// Quo truncates toward zero, like the Float case
+				return normalizeInteger(new(big.Int).Quo(arg.Value.Num(), arg.Value.Denom()))
+
+			case *object.String:
+				val, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+
+				if !ok {
+					return newError("int: Could not parse %q as integer", arg.Value)
+				}
+
+				return normalizeInteger(val)
+
+			default:
+				return newError("int: No implementation for argument type %T. Expected=%s, %s or %s", arg, object.IntegerObj, object.FloatObj, object.StringObj)
+
+			}
+
+		},
+	},
+	"rational": {
+		Params: []string{"num", "denom"},
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) == 2 {
+
+				if args[0].Type() != object.IntegerObj || args[1].Type() != object.IntegerObj {
+					return newError("rational: No implementation for argument types %s, %s. Expected=%s, %s", args[0].Type(), args[1].Type(), object.IntegerObj, object.IntegerObj)
+				}
+
+				denom := toBigInt(args[1])
+
+				if denom.Sign() == 0 {
+					return newError("rational: division by zero")
+				}
+
+				return &object.Rational{Value: new(big.Rat).SetFrac(toBigInt(args[0]), denom)}
+
+			}
+
+			if len(args) != 1 {
+				return newError("rational: Got wrong number of args. Expected=%d or %d. Got=%d", 1, 2, len(args))
+			}
+
+			switch arg := args[0].(type) {
+
+			case *object.Rational:
+				return arg
+
+			case *object.Integer, *object.BigInteger:
+				return &object.Rational{Value: toBigRat(arg)}
+
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("rational: Cannot convert %s to a rational", arg.Inspect())
+				}
+
+				// exact: rational(0.1) shows the binary value the float really holds
+				return &object.Rational{Value: new(big.Rat).SetFloat64(arg.Value)}
+
+			case *object.String:
+				val, ok := new(big.Rat).SetString(strings.TrimSpace(arg.Value))
+
+				if !ok {
+					return newError("rational: Could not parse %q as rational", arg.Value)
+				}
+
+				return &object.Rational{Value: val}
+
+			default:
+				return newError("rational: No implementation for argument type %T. Expected=%s, %s or %s", arg, object.IntegerObj, object.FloatObj, object.StringObj)
+
+			}
+
+		},
+	},
+	"range": {
+		Params:   []string{"start", "stop", "step"},
+		Defaults: []object.Object{&object.Integer{Value: 0}, nil, &object.Integer{Value: 1}},
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) < 1 || len(args) > 3 {
+				return newError("range: Got wrong number of args. Expected=%d to %d. Got=%d", 1, 3, len(args))
+			}
+
+			bounds := make([]int64, len(args))
+
+			for i, arg := range args {
+
+				integer, ok := arg.(*object.Integer)
+
+				if !ok {
+					return newError("range: No implementation for argument type %T. Expected=%s", arg, object.IntegerObj)
+				}
+
+				bounds[i] = integer.Value
+
+			}
+
+			// range(stop), range(start, stop) or range(start, stop, step)
+			r := &object.Range{Stop: bounds[0], Step: 1}
+
+			if len(bounds) > 1 {
+				r.Start, r.Stop = bounds[0], bounds[1]
+			}
+
+			if len(bounds) > 2 {
+				r.Step = bounds[2]
+			}
+
+			if r.Step == 0 {
+				return newError("range: step must not be zero")
+			}
+
+			// lengths and indexes are int64, so every element must be reachable by one
+			if r.Len() < 0 {
+				return newError("range: too many elements. Expected at most %d", int64(math.MaxInt64))
+			}
+
+			return r
+
+		},
+	},
 	"puts": {
 		Fn: func(args ...object.Object) object.Object {
 
@@ -130,3 +338,140 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 }
+
+// The higher order builtins call back into the evaluator through applyFn,
+// which refers to builtins itself, so they are added here to avoid an
+// initialization cycle. Each takes the array first so it can sit on the right
+// of a pipe: arr |> map(fn(x) { x * 2 }) |> sum()
+func init() {
+
+	builtins["map"] = &object.Builtin{
+		Params: []string{"array", "func"},
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) != 2 {
+				return newError("map: Got wrong number of args. Expected=%d. Got=%d", 2, len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+
+			if !ok {
+				return newError("map: No implementation for argument type %T. Expected=%s", args[0], object.ArrayObj)
+			}
+
+			mapped := make([]object.Object, len(arr.Elements))
+
+			for i, el := range arr.Elements {
+
+				res := applyFn(args[1], []object.Object{el})
+
+				if isError(res) {
+					return res
+				}
+
+				mapped[i] = res
+
+			}
+
+			return &object.Array{Elements: mapped}
+
+		},
+	}
+
+	builtins["filter"] = &object.Builtin{
+		Params: []string{"array", "func"},
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) != 2 {
+				return newError("filter: Got wrong number of args. Expected=%d. Got=%d", 2, len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+
+			if !ok {
+				return newError("filter: No implementation for argument type %T. Expected=%s", args[0], object.ArrayObj)
+			}
+
+			kept := []object.Object{}
+
+			for _, el := range arr.Elements {
+
+				res := applyFn(args[1], []object.Object{el})
+
+				if isError(res) {
+					return res
+				}
+
+				if isTruthy(res) {
+					kept = append(kept, el)
+				}
+
+			}
+
+			return &object.Array{Elements: kept}
+
+		},
+	}
+
+	builtins["reduce"] = &object.Builtin{
+		Params: []string{"array", "initial", "func"},
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) != 3 {
+				return newError("reduce: Got wrong number of args. Expected=%d. Got=%d", 3, len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+
+			if !ok {
+				return newError("reduce: No implementation for argument type %T. Expected=%s", args[0], object.ArrayObj)
+			}
+
+			acc := args[1]
+
+			for _, el := range arr.Elements {
+
+				acc = applyFn(args[2], []object.Object{acc, el})
+
+				if isError(acc) {
+					return acc
+				}
+
+			}
+
+			return acc
+
+		},
+	}
+
+	builtins["sum"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+
+			if len(args) != 1 {
+				return newError("sum: Got wrong number of args. Expected=%d. Got=%d", 1, len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+
+			if !ok {
+				return newError("sum: No implementation for argument type %T. Expected=%s", args[0], object.ArrayObj)
+			}
+
+			var total object.Object = &object.Integer{Value: 0}
+
+			for _, el := range arr.Elements {
+
+				if !isNumeric(el) {
+					return newError("sum: No implementation for element type %s. Expected a number", el.Type())
+				}
+
+				total = evalInfixExpression("+", total, el)
+
+			}
+
+			return total
+
+		},
+	}
+
+}
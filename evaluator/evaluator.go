@@ -2,6 +2,9 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 
 	"github.com/Sheep42/Monkey-Lang/ast"
 	"github.com/Sheep42/Monkey-Lang/object"
@@ -12,10 +15,30 @@ var (
 	Null  = &object.Null{}
 	True  = &object.Boolean{Value: true}
 	False = &object.Boolean{Value: false}
+
+	// loop control signals, see evalWhileStatement
+	breakSignal    = &object.Break{}
+	continueSignal = &object.Continue{}
 )
 
-// Eval evaluates the AST
-func Eval(node ast.Node, env *object.Environment) object.Object {
+// Eval evaluates the AST. It is the recovery boundary for the evaluator: a Go
+// panic anywhere below it is returned as an internal error object instead of
+// crashing the host program.
+func Eval(node ast.Node, env *object.Environment) (res object.Object) {
+
+	defer func() {
+
+		if r := recover(); r != nil {
+			res = &object.Error{Message: fmt.Sprintf("internal error: %v", r), Internal: true}
+		}
+
+	}()
+
+	return eval(node, env)
+
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 
 	switch node := node.(type) {
 
@@ -24,12 +47,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return eval(node.Expression, env)
 
 	// Expressions
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
+
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.RationalLiteral:
+		return &object.Rational{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -39,35 +72,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isErrorOrSignal(elements[0]) {
 			return elements[0]
 		}
 
 		return &object.Array{Elements: elements}
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-
-		if isError(left) {
-			return left
-		}
+		res, _ := evalChain(node, env)
+		return res
 
-		index := Eval(node.Index, env)
-
-		if isError(index) {
-			return index
-		}
-
-		return evalIndexExpression(left, index)
+	case *ast.MemberExpression:
+		res, _ := evalChain(node, env)
+		return res
 
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 
 	case *ast.PrefixExpression:
 
-		right := Eval(node.Right, env)
+		right := eval(node.Right, env)
 
-		if isError(right) {
+		if isErrorOrSignal(right) {
 			return right
 		}
 
@@ -75,13 +101,31 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.InfixExpression:
 
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := eval(node.Left, env)
+		if isErrorOrSignal(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
-		if isError(right) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
+
+		if node.Operator == "|>" {
+			return evalPipeExpression(left, node.Right, env)
+		}
+
+		if node.Operator == "??" {
+
+			if left != Null {
+				return left
+			}
+
+			return eval(node.Right, env)
+
+		}
+
+		right := eval(node.Right, env)
+		if isErrorOrSignal(right) {
 			return right
 		}
 
@@ -93,54 +137,204 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return evalIfElseExpression(node, env)
 
+	case *ast.ConditionalExpression:
+		condition := eval(node.Condition, env)
+
+		if isErrorOrSignal(condition) {
+			return condition
+		}
+
+		if isTruthy(condition) {
+			return eval(node.Consequence, env)
+		}
+
+		return eval(node.Alternative, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
 	case *ast.ReturnStatement:
 
-		val := Eval(node.ReturnValue, env)
+		val := eval(node.ReturnValue, env)
 
-		if isError(val) {
+		if isErrorOrSignal(val) {
 			return val
 		}
 
 		return &object.ReturnValue{Value: val}
 
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return breakSignal
+
+	case *ast.ContinueStatement:
+		return continueSignal
+
 	case *ast.LetStatement:
 
-		val := Eval(node.Value, env)
+		val := eval(node.Value, env)
 
-		if isError(val) {
+		if isErrorOrSignal(val) {
 			return val
 		}
 
+		if node.Pattern != nil {
+			return destructure(node.Pattern, val, env)
+		}
+
 		env.Set(node.Name.Value, val)
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
 	case *ast.FunctionLiteral:
 
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Name: node.Name, Parameters: params, Body: body, Env: env}
+
+	case *ast.BadStatement, *ast.BadExpression:
+		return newError("cannot evaluate code with syntax errors")
 
 	case *ast.CallExpression:
+		res, _ := evalChain(node, env)
+		return res
 
-		fn := Eval(node.Function, env)
+	}
 
-		if isError(fn) {
-			return fn
+	return nil
+
+}
+
+// evalChain evaluates a chain of member, index and call expressions, such as
+// h?.f(x)["k"]. It reports whether an optional link found null, in which case
+// the rest of the chain is skipped and the whole chain is null.
+func evalChain(node ast.Expression, env *object.Environment) (object.Object, bool) {
+
+	switch node := node.(type) {
+
+	case *ast.MemberExpression:
+		obj, skipped := evalChain(node.Object, env)
+
+		if skipped || isErrorOrSignal(obj) {
+			return obj, skipped
+		}
+
+		if node.Optional && obj == Null {
+			return Null, true
+		}
+
+		if obj.Type() != object.HashObj {
+			return newError("%s has no property %s", obj.Type(), node.Property.Value), false
+		}
+
+		return evalHashIndexExpression(obj, &object.String{Value: node.Property.Value}), false
+
+	case *ast.IndexExpression:
+		left, skipped := evalChain(node.Left, env)
+
+		if skipped || isErrorOrSignal(left) {
+			return left, skipped
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		if node.Optional && left == Null {
+			return Null, true
+		}
+
+		index := eval(node.Index, env)
 
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		if isErrorOrSignal(index) {
+			return index, false
 		}
 
-		return applyFn(fn, args)
+		return evalIndexExpression(left, index), false
+
+	case *ast.CallExpression:
+		return evalCall(node, nil, env)
 
 	}
 
-	return nil
+	return eval(node, env), false
+
+}
+
+// evalCall evaluates a call in a chain, passing leading before the call's own
+// arguments. It reports whether the chain was cut short, like evalChain.
+func evalCall(call *ast.CallExpression, leading []object.Object, env *object.Environment) (object.Object, bool) {
+
+	var fn object.Object
+	var skipped bool
+
+	if member, ok := call.Function.(*ast.MemberExpression); ok {
+		fn, leading, skipped = evalMethod(member, leading, call.Optional, env)
+	} else {
+		fn, skipped = evalChain(call.Function, env)
+	}
+
+	if skipped || isErrorOrSignal(fn) {
+		return fn, skipped
+	}
+
+	if call.Optional && fn == Null {
+		return Null, true
+	}
+
+	args, err := evalArguments(fn, leading, call.Arguments, env)
+
+	if err != nil {
+		return err, false
+	}
+
+	return applyFn(fn, args), false
+
+}
+
+// evalMethod resolves receiver.name(...) to the function to call, along with
+// the leading arguments to pass it. A callable stored in a hash under name is
+// called as is. Otherwise the builtin called name is used, with the receiver
+// as its first argument, so arr.push(4).len() is len(push(arr, 4)). If the
+// call is optional a missing method is null instead of an error.
+func evalMethod(member *ast.MemberExpression, leading []object.Object, optional bool, env *object.Environment) (object.Object, []object.Object, bool) {
+
+	receiver, skipped := evalChain(member.Object, env)
+
+	if skipped || isErrorOrSignal(receiver) {
+		return receiver, nil, skipped
+	}
+
+	if member.Optional && receiver == Null {
+		return Null, nil, true
+	}
+
+	name := member.Property.Value
+
+	if hash, ok := receiver.(*object.Hash); ok {
+
+		pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+
+		if ok && isCallable(pair.Value) {
+			return pair.Value, leading, false
+		}
+
+	}
+
+	if builtin, ok := builtins[name]; ok {
+		return builtin, append([]object.Object{receiver}, leading...), false
+	}
+
+	if optional {
+		return Null, nil, true
+	}
+
+	return newError("%s has no method %s", receiver.Type(), name), nil, false
 
 }
 
@@ -156,13 +350,26 @@ func isError(obj object.Object) bool {
 
 }
 
+// isLoopSignal reports whether obj is a break or continue signal, which must
+// reach the enclosing loop instead of being used as a value
+func isLoopSignal(obj object.Object) bool {
+	return obj == breakSignal || obj == continueSignal
+}
+
+// isErrorOrSignal reports whether obj has to be passed up instead of being used
+// as a value. A break in an if expression produces the signal as that
+// expression's result, so every place that consumes a result checks for it.
+func isErrorOrSignal(obj object.Object) bool {
+	return isError(obj) || isLoopSignal(obj)
+}
+
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 
 	var res object.Object
 
 	for _, stmt := range program.Statements {
 
-		res = Eval(stmt, env)
+		res = eval(stmt, env)
 
 		// bail out early if we hit a return or error
 		switch res := res.(type) {
@@ -187,14 +394,14 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 	for _, stmt := range block.Statements {
 
-		res = Eval(stmt, env)
+		res = eval(stmt, env)
 
 		if res != nil {
 
 			t := res.Type()
 
-			// bail out early if we hit a return or error
-			if t == object.ReturnValueObj || t == object.ErrorObj {
+			// bail out early if we hit a return, error or loop control signal
+			if t == object.ReturnValueObj || t == object.ErrorObj || t == object.BreakObj || t == object.ContinueObj {
 				return res
 			}
 
@@ -225,6 +432,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalNegationOperatorExpression(right)
+	case "~":
+		return evalBitwiseNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -237,9 +446,20 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
 		return evalInfixIntegerExpression(operator, left, right)
 
+	// mixed numbers promote from integer to rational to float, so an inexact
+	// float operand always gives a float
+	case isNumeric(left) && isNumeric(right) && (left.Type() == object.FloatObj || right.Type() == object.FloatObj):
+		return evalInfixFloatExpression(operator, left, right)
+
+	case isNumeric(left) && isNumeric(right):
+		return evalInfixRationalExpression(operator, left, right)
+
 	case left.Type() == object.StringObj && right.Type() == object.StringObj:
 		return evalInfixStringExpression(operator, left, right)
 
+	case operator == ">>" && isCallable(left) && isCallable(right):
+		return composeFns(left, right)
+
 	case operator == "==":
 		return nativeBoolToBooleanObj(left == right)
 
@@ -257,22 +477,98 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 
 func evalInfixIntegerExpression(operator string, left, right object.Object) object.Object {
 
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	leftInt, leftOk := left.(*object.Integer)
+	rightInt, rightOk := right.(*object.Integer)
+
+	// at least one side has already outgrown int64
+	if !leftOk || !rightOk {
+		return evalInfixBigIntegerExpression(operator, left, right)
+	}
+
+	leftVal := leftInt.Value
+	rightVal := rightInt.Value
 
 	switch operator {
 
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		if res, ok := mulInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: res}
+		}
+
+		return evalInfixBigIntegerExpression(operator, left, right)
+
+	case "/", "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+
+		if operator == "%" {
+			return &object.Integer{Value: leftVal % rightVal}
+		}
+
+		// the one quotient that doesn't fit: MinInt64 / -1
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalInfixBigIntegerExpression(operator, left, right)
+		}
 
-	case "/":
 		return &object.Integer{Value: leftVal / rightVal}
 
+	case "**":
+		// a negative exponent can't produce an integer, and 0 ** -n is 1 / 0
+		if rightVal < 0 {
+
+			if leftVal == 0 {
+				return newError("division by zero")
+			}
+
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+
+		}
+
+		if res, ok := powInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: res}
+		}
+
+		return evalInfixBigIntegerExpression(operator, left, right)
+
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+
+		if operator == ">>" {
+			return &object.Integer{Value: leftVal >> uint64(rightVal)}
+		}
+
+		// shifting back must give the original value, or bits were lost
+		if rightVal < 64 && (leftVal<<uint64(rightVal))>>uint64(rightVal) == leftVal {
+			return &object.Integer{Value: leftVal << uint64(rightVal)}
+		}
+
+		return evalInfixBigIntegerExpression(operator, left, right)
+
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		if res, ok := addInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: res}
+		}
+
+		return evalInfixBigIntegerExpression(operator, left, right)
 
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		if res, ok := subInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: res}
+		}
+
+		return evalInfixBigIntegerExpression(operator, left, right)
 
 	case "<":
 		return nativeBoolToBooleanObj(leftVal < rightVal)
@@ -280,6 +576,12 @@ func evalInfixIntegerExpression(operator string, left, right object.Object) obje
 	case ">":
 		return nativeBoolToBooleanObj(leftVal > rightVal)
 
+	case "<=":
+		return nativeBoolToBooleanObj(leftVal <= rightVal)
+
+	case ">=":
+		return nativeBoolToBooleanObj(leftVal >= rightVal)
+
 	case "==":
 		return nativeBoolToBooleanObj(leftVal == rightVal)
 
@@ -291,159 +593,664 @@ func evalInfixIntegerExpression(operator string, left, right object.Object) obje
 	}
 }
 
-func evalInfixStringExpression(operator string, left, right object.Object) object.Object {
+func evalInfixFloatExpression(operator string, left, right object.Object) object.Object {
 
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 
 	switch operator {
 
-	case "+":
-		return &object.String{Value: leftVal + rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
 
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
-}
+	case "/", "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 
-func evalBangOperatorExpression(right object.Object) object.Object {
+		if operator == "/" {
+			return &object.Float{Value: leftVal / rightVal}
+		}
 
-	switch right {
-	case True:
-		return False
-	case False:
-		return True
-	case Null:
-		return True
-	default:
-		return False
-	}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 
-}
+	case "**":
+		if leftVal == 0 && rightVal < 0 {
+			return newError("division by zero")
+		}
 
-func evalNegationOperatorExpression(right object.Object) object.Object {
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 
-	if right.Type() != object.IntegerObj {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
 
-		return newError("unknown operator: -%s", right.Type())
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
 
-	}
+	case "<":
+		return nativeBoolToBooleanObj(leftVal < rightVal)
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	case ">":
+		return nativeBoolToBooleanObj(leftVal > rightVal)
 
-}
+	case "<=":
+		return nativeBoolToBooleanObj(leftVal <= rightVal)
 
-func evalIfElseExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	case ">=":
+		return nativeBoolToBooleanObj(leftVal >= rightVal)
 
-	condition := Eval(ie.Condition, env)
+	case "==":
+		return nativeBoolToBooleanObj(leftVal == rightVal)
 
-	if isError(condition) {
-		return condition
-	}
+	case "!=":
+		return nativeBoolToBooleanObj(leftVal != rightVal)
 
-	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
-	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
-	} else {
-		return Null
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
-
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-
-	if val, ok := env.Get(node.Value); ok {
-		return val
-	}
-
-	if builtin, ok := builtins[node.Value]; ok {
-		return builtin
-	}
+func isNumeric(obj object.Object) bool {
 
-	return newError("identifier not found: %s", node.Value)
+	t := obj.Type()
+	return t == object.IntegerObj || t == object.FloatObj || t == object.RationalObj
 
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+// toFloat converts a numeric object to a float64. obj must satisfy isNumeric.
+func toFloat(obj object.Object) float64 {
 
-	switch {
+	switch obj := obj.(type) {
 
-	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
-		return evalArrayIndexExpression(left, index)
+	case *object.Integer:
+		return float64(obj.Value)
 
-	case left.Type() == object.HashObj:
-		return evalHashIndexExpression(left, index)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+
+	case *object.Rational:
+		f, _ := obj.Value.Float64()
+		return f
 
 	default:
-		return newError("Index operator not supported: %s[%s]", left.Type(), index.Type())
+		return obj.(*object.Float).Value
 
 	}
 
 }
 
-func evalArrayIndexExpression(array, index object.Object) object.Object {
+// evalLogicalExpression short-circuits && and ||, only evaluating the right
+// operand when the left one doesn't decide the result
+func evalLogicalExpression(operator string, left object.Object, rightNode ast.Expression, env *object.Environment) object.Object {
 
-	arr := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arr.Elements) - 1)
+	if operator == "&&" && !isTruthy(left) {
+		return False
+	}
 
-	if idx < 0 || idx > max {
-		return Null
+	if operator == "||" && isTruthy(left) {
+		return True
 	}
 
-	return arr.Elements[idx]
+	right := eval(rightNode, env)
 
-}
+	if isErrorOrSignal(right) {
+		return right
+	}
 
-func evalHashIndexExpression(hash, index object.Object) object.Object {
+	return nativeBoolToBooleanObj(isTruthy(right))
 
-	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+}
 
-	if !ok {
-		return newError("Invalid HashKey: %q. Type %q is unsupported.", index.Inspect(), index.Type())
-	}
+// evalPipeExpression passes left as the first argument of the call on the
+// right, so x |> f(y) is f(x, y). Anything else on the right has to evaluate
+// to a callable, which gets left as its only argument: x |> f is f(x).
+func evalPipeExpression(left object.Object, right ast.Expression, env *object.Environment) object.Object {
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	call, ok := right.(*ast.CallExpression)
 
 	if !ok {
-		return Null
-	}
 
-	return pair.Value
+		fn := eval(right, env)
 
-}
+		if isErrorOrSignal(fn) {
+			return fn
+		}
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+		return applyFn(fn, []object.Object{left})
 
-	pairs := make(map[object.HashKey]object.HashPair)
+	}
 
-	for keyNode, valNode := range node.Pairs {
+	res, _ := evalCall(call, []object.Object{left}, env)
 
-		key := Eval(keyNode, env)
+	return res
 
-		if isError(key) {
-			return key
-		}
+}
 
-		hashKey, ok := key.(object.Hashable)
+// composeFns returns a function that calls f and passes the result on to g,
+// so (f >> g)(x) is g(f(x))
+func composeFns(f, g object.Object) object.Object {
 
-		if !ok {
-			return newError("Invalid HashKey: %q. Type %q is unsupported.", key.Inspect(), key.Type())
-		}
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
 
-		val := Eval(valNode, env)
+		res := applyFn(f, args)
 
-		if isError(val) {
-			return val
+		if isError(res) {
+			return res
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: val}
+		return applyFn(g, []object.Object{res})
 
-	}
+	}}
+
+}
+
+func evalInfixStringExpression(operator string, left, right object.Object) object.Object {
+
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+
+	switch right {
+	case True:
+		return False
+	case False:
+		return True
+	case Null:
+		return True
+	default:
+		return False
+	}
+
+}
+
+func evalNegationOperatorExpression(right object.Object) object.Object {
+
+	switch right := right.(type) {
+
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return normalizeInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+
+		return &object.Integer{Value: -right.Value}
+
+	case *object.BigInteger:
+		return normalizeInteger(new(big.Int).Neg(right.Value))
+
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+
+	case *object.Rational:
+		return &object.Rational{Value: new(big.Rat).Neg(right.Value)}
+
+	default:
+		return newError("unknown operator: -%s", right.Type())
+
+	}
+
+}
+
+func evalBitwiseNotOperatorExpression(right object.Object) object.Object {
+
+	switch right := right.(type) {
+
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+
+	case *object.BigInteger:
+		return normalizeInteger(new(big.Int).Not(right.Value))
+
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+
+	}
+
+}
+
+// evalWhileStatement runs the loop body until the condition is falsy or the
+// body breaks. Return values and errors end the loop and are passed on to the
+// enclosing block. The parser only allows break and continue inside a loop of
+// the same function, so the signals never escape.
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+
+	for {
+
+		condition := eval(ws.Condition, env)
+
+		if isErrorOrSignal(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return Null
+		}
+
+		if stop, res := loopControl(eval(ws.Body, env)); stop {
+			return res
+		}
+
+	}
+
+}
+
+// evalForStatement runs the loop body once per element of an array, string,
+// hash or range. Every iteration gets its own environment, so closures created
+// in the body capture that iteration's values.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+
+	iterable := eval(fs.Iterable, env)
+
+	if isErrorOrSignal(iterable) {
+		return iterable
+	}
+
+	var res object.Object
+
+	// iterate runs the body for one element and reports whether to stop
+	iterate := func(key, value object.Object) bool {
+
+		iterEnv := object.NewEnclosedEnvironment(env)
+
+		if fs.Key != nil {
+			iterEnv.Set(fs.Key.Value, key)
+		}
+
+		iterEnv.Set(fs.Value.Value, value)
+
+		var stop bool
+		stop, res = loopControl(eval(fs.Body, iterEnv))
+
+		return stop
+
+	}
+
+	switch iterable := iterable.(type) {
+
+	case *object.Array:
+		for i, el := range iterable.Elements {
+
+			if iterate(&object.Integer{Value: int64(i)}, el) {
+				return res
+			}
+
+		}
+
+	case *object.String:
+		i := int64(0)
+
+		for _, r := range iterable.Value {
+
+			if iterate(&object.Integer{Value: i}, &object.String{Value: string(r)}) {
+				return res
+			}
+
+			i++
+
+		}
+
+	case *object.Hash:
+		for _, pair := range iterable.SortedPairs() {
+
+			key, value := pair.Key, pair.Value
+
+			if fs.Key == nil {
+				value = pair.Key
+			}
+
+			if iterate(key, value) {
+				return res
+			}
+
+		}
+
+	case *object.Range:
+		for i := int64(0); i < iterable.Len(); i++ {
+
+			if iterate(&object.Integer{Value: i}, &object.Integer{Value: iterable.At(i)}) {
+				return res
+			}
+
+		}
+
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+
+	}
+
+	return Null
+
+}
+
+// loopControl interprets the result of one run of a loop body. It reports
+// whether the loop must stop, and if so what the loop statement evaluates to:
+// a return value or error is passed on, a break makes the loop evaluate to null.
+func loopControl(res object.Object) (bool, object.Object) {
+
+	if res == nil {
+		return false, nil
+	}
+
+	switch res.Type() {
+
+	case object.ReturnValueObj, object.ErrorObj:
+		return true, res
+
+	case object.BreakObj:
+		return true, Null
+
+	}
+
+	return false, nil
+
+}
+
+func evalIfElseExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+
+	condition := eval(ie.Condition, env)
+
+	if isErrorOrSignal(condition) {
+		return condition
+	}
+
+	var res object.Object
+
+	if isTruthy(condition) {
+		res = eval(ie.Consequence, env)
+	} else if ie.ElseIf != nil {
+		res = evalIfElseExpression(ie.ElseIf, env)
+	} else if ie.Alternative != nil {
+		res = eval(ie.Alternative, env)
+	}
+
+	// a branch that is empty or ends in a statement without a value is null
+	if res == nil {
+		return Null
+	}
+
+	return res
+
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+
+	return newError("identifier not found: %s", node.Value)
+
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+
+	switch target := node.Target.(type) {
+
+	case *ast.IndexExpression:
+		left := eval(target.Left, env)
+
+		if isErrorOrSignal(left) {
+			return left
+		}
+
+		index := eval(target.Index, env)
+
+		if isErrorOrSignal(index) {
+			return index
+		}
+
+		return evalIndexAssignExpression(node, left, index, env)
+
+	case *ast.MemberExpression:
+		left := eval(target.Object, env)
+
+		if isErrorOrSignal(left) {
+			return left
+		}
+
+		return evalIndexAssignExpression(node, left, &object.String{Value: target.Property.Value}, env)
+
+	}
+
+	ident, ok := node.Target.(*ast.Identifier)
+
+	if !ok {
+		return newError("cannot assign to %s", node.Target.String())
+	}
+
+	val := eval(node.Value, env)
+
+	if isErrorOrSignal(val) {
+		return val
+	}
+
+	// compound operators: x += 1 is x = x + 1
+	if node.Operator != "=" {
+
+		cur, ok := env.Get(ident.Value)
+
+		if !ok {
+			return newError("identifier not found: %s", ident.Value)
+		}
+
+		val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), cur, val)
+
+		if isErrorOrSignal(val) {
+			return val
+		}
+
+	}
+
+	if _, ok := env.Assign(ident.Value, val); !ok {
+		return newError("cannot assign to undeclared identifier: %s. Declare it with let first", ident.Value)
+	}
+
+	return val
+
+}
+
+// evalIndexAssignExpression updates an array element or a hash entry in place.
+// Arrays and hashes are references, so the change is visible through every
+// name bound to the same value. Builtins such as push copy instead.
+func evalIndexAssignExpression(node *ast.AssignExpression, left, index object.Object, env *object.Environment) object.Object {
+
+	val := eval(node.Value, env)
+
+	if isErrorOrSignal(val) {
+		return val
+	}
+
+	switch container := left.(type) {
+
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+
+		if !ok {
+
+			if index.Type() != object.IntegerObj {
+				return newError("Index assignment not supported: %s[%s]", left.Type(), index.Type())
+			}
+
+			return newError("index out of range: %s with length %d", index.Inspect(), len(container.Elements))
+
+		}
+
+		if idx.Value < 0 || idx.Value >= int64(len(container.Elements)) {
+			return newError("index out of range: %d with length %d", idx.Value, len(container.Elements))
+		}
+
+		if node.Operator != "=" {
+
+			val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), container.Elements[idx.Value], val)
+
+			if isErrorOrSignal(val) {
+				return val
+			}
+
+		}
+
+		container.Elements[idx.Value] = val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+
+		if !ok {
+			return newError("Invalid HashKey: %q. Type %q is unsupported.", index.Inspect(), index.Type())
+		}
+
+		hashed := key.HashKey()
+
+		if node.Operator != "=" {
+
+			pair, ok := container.Pairs[hashed]
+
+			if !ok {
+				return newError("key not found: %s", index.Inspect())
+			}
+
+			val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), pair.Value, val)
+
+			if isErrorOrSignal(val) {
+				return val
+			}
+
+		}
+
+		container.Pairs[hashed] = object.HashPair{Key: index, Value: val}
+
+	default:
+		return newError("Index assignment not supported: %s[%s]", left.Type(), index.Type())
+
+	}
+
+	return val
+
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+
+	switch {
+
+	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
+		return evalArrayIndexExpression(left, index)
+
+	case left.Type() == object.StringObj && index.Type() == object.IntegerObj:
+		return evalStringIndexExpression(left, index)
+
+	case left.Type() == object.HashObj:
+		return evalHashIndexExpression(left, index)
+
+	default:
+		return newError("Index operator not supported: %s[%s]", left.Type(), index.Type())
+
+	}
+
+}
+
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+
+	arr := array.(*object.Array)
+	idxObj, ok := index.(*object.Integer)
+
+	// a BigInteger index is always out of range
+	if !ok {
+		return Null
+	}
+
+	idx := idxObj.Value
+	max := int64(len(arr.Elements) - 1)
+
+	if idx < 0 || idx > max {
+		return Null
+	}
+
+	return arr.Elements[idx]
+
+}
+
+// evalStringIndexExpression indexes a string by code point, not by byte. Use
+// the bytes builtin for byte-level access.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+
+	runes := []rune(str.(*object.String).Value)
+	idxObj, ok := index.(*object.Integer)
+
+	if !ok {
+		return Null
+	}
+
+	idx := idxObj.Value
+
+	if idx < 0 || idx >= int64(len(runes)) {
+		return Null
+	}
+
+	return &object.String{Value: string(runes[idx])}
+
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+
+	hashObject := hash.(*object.Hash)
+	key, ok := index.(object.Hashable)
+
+	if !ok {
+		return newError("Invalid HashKey: %q. Type %q is unsupported.", index.Inspect(), index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+
+	if !ok {
+		return Null
+	}
+
+	return pair.Value
+
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for keyNode, valNode := range node.Pairs {
+
+		key := eval(keyNode, env)
+
+		if isErrorOrSignal(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+
+		if !ok {
+			return newError("Invalid HashKey: %q. Type %q is unsupported.", key.Inspect(), key.Type())
+		}
+
+		val := eval(valNode, env)
+
+		if isErrorOrSignal(val) {
+			return val
+		}
+
+		hashed := hashKey.HashKey()
+		pairs[hashed] = object.HashPair{Key: key, Value: val}
+
+	}
 
 	return &object.Hash{Pairs: pairs}
 
@@ -455,9 +1262,9 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 	for _, e := range exps {
 
-		evaluated := Eval(e, env)
+		evaluated := eval(e, env)
 
-		if isError(evaluated) {
+		if isErrorOrSignal(evaluated) {
 			return []object.Object{evaluated}
 		}
 
@@ -469,15 +1276,218 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 }
 
+// evalArguments evaluates the arguments of a call to fn, following any
+// already evaluated leading arguments, and moves keyword arguments to the
+// position of the parameter they name. Parameters skipped over by a keyword
+// argument are left nil, to be filled in from defaults.
+func evalArguments(fn object.Object, leading []object.Object, exps []ast.Expression, env *object.Environment) ([]object.Object, object.Object) {
+
+	args := append([]object.Object{}, leading...)
+
+	var names []string
+
+	for _, exp := range exps {
+
+		kw, ok := exp.(*ast.KeywordArgument)
+
+		if !ok {
+
+			val := eval(exp, env)
+
+			if isErrorOrSignal(val) {
+				return nil, val
+			}
+
+			args = append(args, val)
+			continue
+
+		}
+
+		if names == nil {
+
+			if !isCallable(fn) {
+				return nil, newError("not a function: %s", fn.Type())
+			}
+
+			if names = paramNames(fn); names == nil {
+				return nil, newError("%s: does not accept keyword arguments", callableName(fn))
+			}
+
+		}
+
+		i := indexOf(names, kw.Name.Value)
+
+		if i < 0 {
+			return nil, newError("%s: unknown keyword argument %s", callableName(fn), kw.Name.Value)
+		}
+
+		if i < len(args) && args[i] != nil {
+			return nil, newError("%s: got multiple values for argument %s", callableName(fn), kw.Name.Value)
+		}
+
+		val := eval(kw.Value, env)
+
+		if isErrorOrSignal(val) {
+			return nil, val
+		}
+
+		for len(args) <= i {
+			args = append(args, nil)
+		}
+
+		args[i] = val
+
+	}
+
+	// apart from any Defaults, builtins have nothing to fill gaps with, so
+	// every argument up to the last one given has to be there
+	if builtin, ok := fn.(*object.Builtin); ok {
+
+		if names != nil && builtin.Defaults != nil {
+
+			for len(args) < len(builtin.Params) {
+				args = append(args, nil)
+			}
+
+			for i, arg := range args {
+
+				if arg == nil {
+					args[i] = builtin.Defaults[i]
+				}
+
+			}
+
+		}
+
+		for i, arg := range args {
+
+			if arg == nil {
+				return nil, newError("%s: missing argument %s", callableName(fn), builtin.Params[i])
+			}
+
+		}
+
+	}
+
+	return args, nil
+
+}
+
+// paramNames returns the names keyword arguments can use for fn's parameters,
+// with "" for parameters that can only be passed by position. It returns nil
+// if fn does not take keyword arguments.
+func paramNames(fn object.Object) []string {
+
+	switch fn := fn.(type) {
+
+	case *object.Function:
+		names := []string{}
+
+		for _, param := range fn.Parameters {
+
+			if def, ok := param.(*ast.DefaultPattern); ok {
+				param = def.Pattern
+			}
+
+			switch param := param.(type) {
+
+			case *ast.Identifier:
+				names = append(names, param.Value)
+
+			case *ast.RestPattern:
+				return names
+
+			default:
+				names = append(names, "")
+
+			}
+
+		}
+
+		return names
+
+	case *object.Builtin:
+		return fn.Params
+
+	}
+
+	return nil
+
+}
+
+func isCallable(obj object.Object) bool {
+
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	}
+
+	return false
+
+}
+
+func indexOf(names []string, name string) int {
+
+	for i, n := range names {
+
+		if n == name {
+			return i
+		}
+
+	}
+
+	return -1
+
+}
+
+// callableName names fn for error messages
+func callableName(fn object.Object) string {
+
+	switch fn := fn.(type) {
+
+	case *object.Function:
+		if fn.Name != "" {
+			return fn.Name
+		}
+
+		return "anonymous function"
+
+	case *object.Builtin:
+		for name, builtin := range builtins {
+
+			if builtin == fn {
+				return name
+			}
+
+		}
+
+	}
+
+	return fn.Inspect()
+
+}
+
 func applyFn(fn object.Object, args []object.Object) object.Object {
 
 	switch fn := fn.(type) {
 
 	case *object.Function:
 
-		extendedEnv := extendFnEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnVal(evaluated)
+		extendedEnv, err := extendFnEnv(fn, args)
+
+		if err != nil {
+			return err
+		}
+
+		evaluated := unwrapReturnVal(eval(fn.Body, extendedEnv))
+
+		// as with if, a body that is empty or ends in a statement without a
+		// value returns null
+		if evaluated == nil {
+			return Null
+		}
+
+		return evaluated
 
 	case *object.Builtin:
 		return fn.Fn(args...)
@@ -489,17 +1499,106 @@ func applyFn(fn object.Object, args []object.Object) object.Object {
 
 }
 
-func extendFnEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFnEnv binds the arguments of a call to fn's parameters in a new
+// environment enclosed by the one fn was defined in. Defaults for missing
+// arguments are evaluated in that environment on every call, so they can
+// refer to earlier parameters.
+func extendFnEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+
+	if err := checkArity(fn, len(args)); err != nil {
+		return nil, err
+	}
 
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for i, param := range fn.Parameters {
 
-		env.Set(param.Value, args[i])
+		if rest, ok := param.(*ast.RestPattern); ok {
+
+			extra := []object.Object{}
+
+			if i < len(args) {
+				extra = make([]object.Object, len(args)-i)
+				copy(extra, args[i:])
+			}
+
+			env.Set(rest.Name.Value, &object.Array{Elements: extra})
+			break
+
+		}
+
+		var arg object.Object
+
+		if i < len(args) {
+			arg = args[i]
+		}
+
+		// a missing argument, either past the end or skipped by a keyword
+		// argument
+		if arg == nil {
+
+			def, ok := param.(*ast.DefaultPattern)
+
+			if !ok {
+				return nil, newError("%s: missing argument %s", callableName(fn), param.String())
+			}
+
+			if arg = eval(def.Default, env); isErrorOrSignal(arg) {
+				return nil, arg
+			}
+
+		}
+
+		if err := destructure(param, arg, env); err != nil {
+			return nil, err
+		}
+
+	}
+
+	return env, nil
+
+}
+
+// checkArity returns an error naming fn if it can't be called with argc
+// arguments
+func checkArity(fn *object.Function, argc int) object.Object {
+
+	required, max := 0, len(fn.Parameters)
+
+	for _, param := range fn.Parameters {
+
+		switch param.(type) {
+
+		case *ast.DefaultPattern:
+			// optional
+
+		case *ast.RestPattern:
+			max = -1
+
+		default:
+			required++
+
+		}
+
+	}
+
+	if argc >= required && (max < 0 || argc <= max) {
+		return nil
+	}
+
+	name := callableName(fn)
+
+	switch {
+
+	case max < 0:
+		return newError("%s: Got wrong number of args. Expected=at least %d. Got=%d", name, required, argc)
+
+	case required == max:
+		return newError("%s: Got wrong number of args. Expected=%d. Got=%d", name, required, argc)
 
 	}
 
-	return env
+	return newError("%s: Got wrong number of args. Expected=%d to %d. Got=%d", name, required, max, argc)
 
 }
 
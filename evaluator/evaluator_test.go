@@ -1,8 +1,10 @@
 package evaluator
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/Sheep42/Monkey-Lang/ast"
 	"github.com/Sheep42/Monkey-Lang/lexer"
 	"github.com/Sheep42/Monkey-Lang/object"
 	"github.com/Sheep42/Monkey-Lang/parser"
@@ -33,6 +35,22 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"-(3 * 3 * 3 + 10)", -37},
 		{"-3 + -3", -6},
 		{"3 - 9", -6},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"1 + 2 * 3 % 4", 3},
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"-16 >> 2", -4},
+		{"~0", -1},
+		{"~5 & 0xF", 10},
+		{"(0xFF00 | 0x00FF) >> 8", 0xFF},
 	}
 
 	for _, tt := range tests {
@@ -44,6 +62,59 @@ func TestEvalIntegerExpression(t *testing.T) {
 
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"-2.5", -2.5},
+		{"7 / 2.0", 3.5},
+		{"7.0 / 2", 3.5},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"10 - 2.5 * 2", 5},
+		{"-(1.5 + 1)", -2.5},
+		{"2 ** -1", 0.5},
+		{"2.0 ** 3", 8},
+		{"7.5 % 2", 1.5},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+
+	}
+
+}
+
+func TestFloatInspect(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"7 / 2.0", "3.5"},
+		{"1e21", "1e+21"},
+		{"-0.25", "-0.25"},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Inspect() was incorrect. Expected=%q. Got=%q", tt.expected, evaluated.Inspect())
+		}
+
+	}
+
+}
+
 func TestEvalStringLiteral(t *testing.T) {
 
 	tests := []struct {
@@ -127,195 +198,1222 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
 		{"(1 > 2) != false", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{`0 && ""`, true},
+		{"if (false) { 1 } || 5", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"0xFF00 | 0x00FF == 0xFFFF", true},
+		{"rational(1, 3) < rational(1, 2)", true},
+		{"rational(4, 2) == 2", true},
+		{"0.1r + 0.2r == 0.3r", true},
+		{"rational(1, 2) >= 0.5", true},
+		{"6 & 1 == 0", true},
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"2 == 2.0", true},
+		{"0.1 + 0.2 != 0.3", true},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+
+	}
+
+}
+
+func TestBangOperator(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if( true ) { 10 }", 10},
+		{"if( false ) { 10 }", nil},
+		{"if( 1 ) { 10 }", 10},
+		{"if( 1 < 2 ) { 10 }", 10},
+		{"if( 1 > 2 ) { 10 }", nil},
+		{"if( 1 < 2 ) { 10 } else { 20 }", 10},
+		{"if( 1 > 2 ) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 } else if (1 < 2) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+		{"let x = 4; if (x == 1) { 1 } else if (x == 2) { 2 } else if (x == 3) { 3 } else if (x == 4) { 4 } else { 5 }", 4},
+		{"let f = fn(n) { if (n < 0) { return -1 } else if (n == 0) { return 0 } 1 }; f(-5) + f(0) * 10 + f(7) * 100", 99},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObj(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 10; 9;", 10},
+		{`
+			if(10 > 1) {
+				if(10 > 1) {
+					return 10;
+				}
+
+				return 1;
+			}
+		`, 10},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+
+	}
+
+}
+
+func TestErrorHandling(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{
+			"5 + true",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"5 + true; 5;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"1.5 + true",
+			"type mismatch: FLOAT + BOOLEAN",
+		},
+		{
+			"5 <= true",
+			"type mismatch: INTEGER <= BOOLEAN",
+		},
+		{
+			"true >= false",
+			"unknown operator: BOOLEAN >= BOOLEAN",
+		},
+		{
+			`"a" % "b"`,
+			"unknown operator: STRING % STRING",
+		},
+		{
+			"2 ** false",
+			"type mismatch: INTEGER ** BOOLEAN",
+		},
+		{
+			"true && undefined",
+			"identifier not found: undefined",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"1 << 100000000000000",
+			"integer too large: 1 << 100000000000000 exceeds 1048576 bits",
+		},
+		{
+			"(1 << 64) << 1048512",
+			"integer too large: 18446744073709551616 << 1048512 exceeds 1048576 bits",
+		},
+		{
+			"2 ** 100000000",
+			"integer too large: 2 ** 100000000 exceeds 1048576 bits",
+		},
+		{
+			"(2 ** 64) ** (2 ** 64)",
+			"integer too large: 18446744073709551616 ** 18446744073709551616 exceeds 1048576 bits",
+		},
+		{
+			"0 ** -1",
+			"division by zero",
+		},
+		{
+			"0 ** -(2 ** 64)",
+			"division by zero",
+		},
+		{
+			"0.0 ** -0.5",
+			"division by zero",
+		},
+		{
+			"rational(1, 2) / 0",
+			"division by zero",
+		},
+		{
+			"rational(1, 2) ** (2 ** 64)",
+			"rational too large: 1/2 ** 18446744073709551616 exceeds 1048576 bits",
+		},
+		{
+			"rational(2, 3) ** -100000000",
+			"rational too large: 2/3 ** -100000000 exceeds 1048576 bits",
+		},
+		{
+			"x = 5",
+			"cannot assign to undeclared identifier: x. Declare it with let first",
+		},
+		{
+			"let f = fn() { y = 1 }; f()",
+			"cannot assign to undeclared identifier: y. Declare it with let first",
+		},
+		{
+			"x += 5",
+			"identifier not found: x",
+		},
+		{
+			"let x = true; x += 1",
+			"type mismatch: BOOLEAN + INTEGER",
+		},
+		{
+			"let x = 1; x /= 0",
+			"division by zero",
+		},
+		{
+			"rational(1, 2) % 2",
+			"unknown operator: RATIONAL % INTEGER",
+		},
+		{
+			"rational(1, 2) ** rational(1, 2)",
+			"unsupported exponent: RATIONAL ** RATIONAL. Expected an INTEGER exponent",
+		},
+		{
+			"rational(1, 0)",
+			"rational: division by zero",
+		},
+		{
+			"rational(1, 2) + true",
+			"type mismatch: RATIONAL + BOOLEAN",
+		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"10 % (5 - 5)",
+			"division by zero",
+		},
+		{
+			"1.5 / 0",
+			"division by zero",
+		},
+		{
+			"let f = fn(x) { 100 / x }; f(0); 5",
+			"division by zero",
+		},
+		{
+			"1.5 & 1",
+			"unknown operator: FLOAT & INTEGER",
+		},
+		{
+			"~true",
+			"unknown operator: ~BOOLEAN",
+		},
+		{
+			"-true",
+			"unknown operator: -BOOLEAN",
+		},
+		{
+			"true + false",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"5; true + false; 5;",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"if(10 > 1) { true + false; }",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			`"Hello" - "World"`,
+			"unknown operator: STRING - STRING",
+		},
+		{
+			`
+				if(10 > 1) {
+
+					if(10 > 1) {
+
+						return true + false;
+
+					}
+
+				}
+
+				return 1;
+			`,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"foobar",
+			"identifier not found: foobar",
+		},
+		{
+			"let = 5; 10;",
+			"cannot evaluate code with syntax errors",
+		},
+		{
+			`{"name": "monkey"}[fn(x) { x }]`,
+			`Invalid HashKey: "fn(x) {\nx\n}". Type "FUNCTION" is unsupported.`,
+		},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+
+			t.Errorf("Evaluated result was not error message. Expected=%s. Got=%T(%+v)", tt.expectedMsg, evaluated, evaluated)
+			continue
+
+		}
+
+		if errObj.Message != tt.expectedMsg {
+
+			t.Errorf("Wrong error message. Expected=%s. Got=%s", tt.expectedMsg, errObj.Message)
+			continue
+
+		}
+
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"2 ** 100", "1267650600228229401496703205376"},
+		{"1 << 64", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"0xFFFFFFFFFFFFFFFFFF", "4722366482869645213695"},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)", "15511210043330985984000000"},
+		{"(2 ** 100) % 7", "2"},
+		{"~(2 ** 64)", "-18446744073709551617"},
+		{"(2 ** 64) / 2.0", "9.223372036854776e+18"},
+		{"(1 << 1048575) >> 1048573", "4"},
+		{"0 << (2 ** 70)", "0"},
+		{"(1 << 70) >> (2 ** 70)", "0"},
+		{"-(1 << 70) >> (2 ** 70)", "-1"},
+		{"(2 ** 524288) >> 524286", "4"},
+		{"(-1) ** (2 ** 70 + 1)", "-1"},
+		{"len([1, 2, 3])", "3"},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. Expected=%s. Got=%v", tt.input, tt.expected, evaluated)
+		}
+
+	}
+
+}
+
+func TestBigIntegersDemote(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807 + 1 - 1", 9223372036854775807},
+		{"(2 ** 100) / (2 ** 98)", 4},
+		{"(1 << 70) >> 69", 2},
+		{"int(\"99999999999999999999\") - 99999999999999999990", 9},
+		{"-9223372036854775808", -9223372036854775808},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+
+	}
+
+}
+
+func TestBigIntegerComparisonsAndHashing(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"2 ** 64 == 2 ** 64", true},
+		{"2 ** 64 != 2 ** 64 + 1", true},
+		{"2 ** 64 > 9223372036854775807", true},
+		{"-(2 ** 64) < 0", true},
+		{"2 ** 64 <= 2.0 ** 64", true},
+		{"2 ** 64 == 1", false},
+		{"{2 ** 64: true}[18446744073709551616]", true},
+		{`{2 ** 64: "big"}[5952119183343170476] == "big"`, false},
+		{`{5952119183343170476: "small"}[2 ** 64] == "small"`, false},
+		{"[1, 2][2 ** 64] == if (false) { 1 }", true},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+
+	}
+
+}
+
+func TestRationals(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1r / 3", "1/3"},
+		{"rational(1, 3)", "1/3"},
+		{"rational(2, -4)", "-1/2"},
+		{"rational(6, 3)", "2/1"},
+		{"0.1r + 0.2r", "3/10"},
+		{"1_000.5r", "2001/2"},
+		{"rational(1, 3) + rational(1, 6)", "1/2"},
+		{"rational(1, 3) * 3", "1/1"},
+		{"1 - rational(1, 4)", "3/4"},
+		{"-rational(1, 4)", "-1/4"},
+		{"rational(2, 3) ** 2", "4/9"},
+		{"rational(2, 3) ** -2", "9/4"},
+		{"1r ** (2 ** 64)", "1/1"},
+		{"rational(-1) ** -(2 ** 64 + 1)", "-1/1"},
+		{"rational(2, 3) ** 20 == rational(2 ** 20, 3 ** 20)", "true"},
+		{"rational(2 ** 64, 3) / 2", "9223372036854775808/3"},
+		{"rational(1, 4) + 0.5", "0.75"},
+		{`rational("3/9")`, "1/3"},
+		{"rational(0.5)", "1/2"},
+		{"float(rational(1, 8))", "0.125"},
+		{"int(rational(-7, 2))", "-3"},
+		{"{rational(2, 4): 1}[rational(1, 2)]", "1"},
+		{"{rational(4, 2): 1}[2]", "1"},
+		{"{2: 1}[rational(4, 2)]", "1"},
+		{"{2 ** 64: 1}[rational(2 ** 65, 2)]", "1"},
+		{`match (rational(4, 2)) { 2 => "two", _ => "other" }`, "two"},
+		{`match (2r) { 2 => "two", _ => "other" }`, "two"},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. Expected=%s. Got=%v", tt.input, tt.expected, evaluated)
+		}
+
+	}
+
+}
+
+func TestPanicsBecomeInternalErrors(t *testing.T) {
+
+	// a hand-built AST with a missing operand, which the parser never produces
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.ExpressionStatement{
+				Expression: &ast.InfixExpression{Operator: "+", Right: &ast.IntegerLiteral{Value: 1}},
+			},
+		},
+	}
+
+	evaluated := Eval(program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if !errObj.Internal {
+		t.Errorf("error is not marked as internal: %q", errObj.Message)
+	}
+
+	if !strings.HasPrefix(errObj.Message, "internal error: ") {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	builtins["explode"] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		panic("boom")
+	}}
+	defer delete(builtins, "explode")
+
+	evaluated = testEval("explode()")
+	errObj, ok = evaluated.(*object.Error)
+
+	if !ok || !errObj.Internal || errObj.Message != "internal error: boom" {
+		t.Errorf("builtin panic was not recovered. got=%T (%+v)", evaluated, evaluated)
+	}
+
+}
+
+func TestLetStatements(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+
+	}
+
+}
+
+func TestAssignExpressions(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 1; let y = 1; x = y = 7; x + y", 14},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 5; x", 5},
+		{"let x = 10; x *= 5; x", 50},
+		{"let x = 10; x /= 5; x", 2},
+		{"let x = 10; x %= 4; x", 2},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn() { let x = 2; x = 3 }; f(); x", 1},
+		{"let counter = fn() { let count = 0; fn() { count += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = fn(n) { n = n * 2; n }; f(21)", 42},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+}
+
+func TestIndexAssignExpressions(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = [1, 2, 3]; a[0] = 10; a[0]", 10},
+		{"let a = [1, 2, 3]; a[2] += 5; a[2]", 8},
+		{"let a = [1, 2, 3]; a[1] = 7", 7},
+		{"let a = [[1], [2]]; a[1][0] = 9; a[1][0]", 9},
+		{`let h = {}; h["k"] = 1; h["k"]`, 1},
+		{`let h = {"k": 1}; h["k"] = 2; h["k"]`, 2},
+		{`let h = {"k": 1}; h["k"] *= 5; h["k"]`, 5},
+		{`let h = {}; h[true] = 1; h[1] = 2; h[true] + h[1]`, 3},
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{"let a = [1]; let f = fn(arr) { arr[0] = 3 }; f(a); a[0]", 3},
+		{"let a = [1]; let b = push(a, 2); b[0] = 5; a[0]", 1},
+		{"let counts = {}; let add = fn(k) { if (counts[k]) { counts[k] += 1 } else { counts[k] = 1 } }; add(\"a\"); add(\"b\"); add(\"a\"); counts[\"a\"]", 2},
+		{"let a = [1, 2, 3]; a[3] = 4", "index out of range: 3 with length 3"},
+		{"let a = [1, 2, 3]; a[-1] = 4", "index out of range: -1 with length 3"},
+		{"let a = [1]; a[2 ** 64] = 4", "index out of range: 18446744073709551616 with length 1"},
+		{`let a = [1]; a["x"] = 4`, "Index assignment not supported: ARRAY[STRING]"},
+		{`let s = "abc"; s[0] = "x"`, "Index assignment not supported: STRING[INTEGER]"},
+		{`let h = {}; h[fn(x) { x }] = 1`, `Invalid HashKey: "fn(x) {\nx\n}". Type "FUNCTION" is unsupported.`},
+		{`let h = {}; h["k"] += 1`, "key not found: k"},
+		{"let a = [true]; a[0] += 1", "type mismatch: BOOLEAN + INTEGER"},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q. got=%q", expected, errObj.Message)
+			}
+
+		}
+
+	}
+
+}
+
+func TestWhileStatements(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; } sum", 15},
+		{"let i = 0; while (i < 5) { i += 1 }", nil},
+		{"let i = 0; while (false) { i = 1 } i", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { break; } } i", 3},
+		{"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue; } sum += i; } sum", 25},
+		{"let i = 0; let n = 0; while (i < 3) { i += 1; let j = 0; while (true) { j += 1; if (j > i) { break } n += 1 } } n", 6},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 4) { return i * 10; } } }; f()", 40},
+		{"let i = 0; while (i < 100000) { i += 1 } i", 100000},
+		{"let i = 0; while (i < 3) { i += 1; let x = if (i == 2) { break } else { i }; } i", 2},
+		{"let i = 0; while (i < 3) { i += 1; undefined } i", "identifier not found: undefined"},
+		{"while (undefined) { }", "identifier not found: undefined"},
+		{"let g = fn() { while (false) { } }; g()", nil},
+		{"let g = fn() { while (true) { break } }; g()", nil},
+		{"let g = fn() { while (false) { } }; g() + 1", "type mismatch: NULL + INTEGER"},
+		{"let i = 0; while (true) { i = if (i == 1) { break } else { i + 1 } } i", 1},
+		{"let n = 0; while (n < 5) { n += 1; [if (n == 2) { break } else { 0 }] } n", 2},
+		{`let n = 0; while (n < 5) { n += 1; let h = {"a": if (n == 2) { break } else { 0 }} } n`, 2},
+		{`let n = 0; while (n < 5) { n += 1; let h = {if (n == 2) { break } else { "a" }: 0} } n`, 2},
+		{"let n = 0; while (n < 5) { n += 1; push([], if (n == 2) { break } else { 0 }) } n", 2},
+		{"let n = 0; while (n < 5) { n += 1; 1 + if (n == 2) { break } else { 0 } } n", 2},
+		{"let n = 0; while (n < 5) { n += 1; -if (n == 2) { break } else { 0 } } n", 2},
+		{"let f = fn(x) { x }; let n = 0; while (n < 5) { n += 1; f(if (n == 2) { break } else { 1 }) } n", 2},
+		{"let f = fn(x) { x }; let n = 0; while (n < 5) { n += 1; f(x: if (n == 2) { break } else { 1 }) } n", 2},
+		{"let a = [0]; let n = 0; while (n < 5) { n += 1; a[0] = if (n == 2) { break } else { n } } a[0]", 1},
+		{`let n = 0; while (n < 5) { n += 1; (if (n == 2) { break } else { {}["x"] }) ?? 1 } n`, 2},
+		{"let n = 0; while (n < 5) { n += 1; (if (n == 2) { break } else { false }) ? 1 : 2 } n", 2},
+		{"let n = 0; while (n < 5) { n += 1; match (if (n == 2) { break } else { n }) { _ => 0 } } n", 2},
+		{"let s = 0; let i = 0; while (i < 3) { i += 1; s += if (i == 2) { continue } else { i } } s", 4},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q. got=%q", expected, errObj.Message)
+			}
+
+		default:
+			testNullObj(t, evaluated)
+
+		}
+
+	}
+
+}
+
+func TestForStatements(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x } sum", 6},
+		{"let sum = 0; for (i, x in [10, 20, 30]) { sum += i * x } sum", 80},
+		{`let s = ""; for (ch in "héllo") { s = ch + s } s`, "olléh"},
+		{`let n = 0; for (i, ch in "abc") { n += i } n`, 3},
+		{`let s = ""; for (k in {"b": 2, "a": 1, "c": 3}) { s += k } s`, "abc"},
+		{`let s = ""; for (k, v in {"b": "2", "a": "1"}) { s += k + ":" + v } s`, "a:1b:2"},
+		{"let sum = 0; for (i in range(5)) { sum += i } sum", 10},
+		{"let sum = 0; for (i in range(2, 10, 3)) { sum += i } sum", 15},
+		{"let sum = 0; for (i in range(5, 0, -2)) { sum += i } sum", 9},
+		{"let sum = 0; for (x in range(100)) { if (x == 5) { break } sum += x } sum", 10},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue } sum += x } sum", 4},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 100 } } }; f()", 200},
+		{"let fns = []; for (x in [1, 2, 3]) { fns = push(fns, fn() { x }) } fns[0]() + fns[2]()", 4},
+		{"for (x in [1]) { let inner = 1 } inner", "identifier not found: inner"},
+		{"let x = 7; for (x in [1, 2]) { } x", 7},
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{"for (x in [1, 2]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"len(range(0, 10, 3))", 4},
+		{"len(range(10, 0))", 0},
+		{"range(1, 2, 0)", "range: step must not be zero"},
+		{"range(-9223372036854775807 - 1, 9223372036854775807)", "range: too many elements. Expected at most 9223372036854775807"},
+		{"range(9223372036854775807, -9223372036854775807 - 1, -2)", "range: too many elements. Expected at most 9223372036854775807"},
+		{"len(range(-9223372036854775807, 9223372036854775807, 2))", 9223372036854775807},
+		{"let h = fn() { for (x in [1]) { } }; h()", nil},
+		{"let h = fn() { for (x in range(5)) { break } }; h()", nil},
+		{"let h = fn() { for (x in []) { } }; h() + 1", "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case string:
+			switch obj := evaluated.(type) {
+
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q. got=%q", expected, obj.Value)
+				}
+
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q. got=%q", expected, obj.Message)
+				}
+
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+
+			}
+
+		default:
+			testNullObj(t, evaluated)
+
+		}
+
+	}
+
+}
+
+func TestMatchExpressions(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match (2) { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`match (7) { 1 => "one", _ => "many" }`, "many"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{`match (-3) { -3 => 1, _ => 2 }`, 1},
+		{`match (1.0) { 1 => "int", 1.0 => "float" }`, "float"},
+		{`match (1r / 2) { 0.5r => 1, _ => 2 }`, 1},
+		{`match (true) { false => 0, true => 1 }`, 1},
+		{"match (5) { n => n * 2 }", 10},
+		{"match ([1, 2, 3]) { [] => 0, [x] => x, [x, y, ...rest] => x + y + len(rest) }", 4},
+		{"match ([1, 2]) { [a, b, c] => 0, [a, b] => a + b }", 3},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", 6},
+		{"match ([1]) { [2, ...] => 0, [1, ...] => 1 }", 1},
+		{`match ({"x": 1, "y": 2}) { {"z": z} => z, {"x": x, "y": y} => x * 10 + y }`, 12},
+		{`match ({"kind": "circle", "r": 3}) { {"kind": "square"} => 0, {"kind": "circle", "r": r} => r }`, 3},
+		{`match ({1: [5]}) { {1: [v]} => v }`, 5},
+		{"match (5) { x if x > 10 => 1, x if x > 3 => 2, _ => 3 }", 2},
+		{"match (5) { 5 => { let y = 2; y * 3 } }", 6},
+		{"let f = fn(x) { match (x) { 0 => { return 100 } _ => 1 }; 200 }; f(0)", 100},
+		{"let x = 1; match (2) { x => x }; x", 1},
+		{"match ([1]) { [a, b] => a, _ => a }", "identifier not found: a"},
+		{"match (3) { 1 => 1, 2 => 2 }", "match: no arm matched 3"},
+		{`match ([1, "a"]) { [1] => 1 }`, "match: no arm matched [1, a]"},
+		{"match (1 + true) { _ => 1 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"match (1) { x if x + true => 1 }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case string:
+			switch obj := evaluated.(type) {
+
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q. got=%q", expected, obj.Value)
+				}
+
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q. got=%q", expected, obj.Message)
+				}
+
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+
+			}
+
+		}
+
+	}
+
+}
+
+func TestDestructuring(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [a, ...rest] = [1, 2, 3]; a + len(rest) * 10", 21},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{"let [_, [b, c]] = [1, [2, 3]]; b + c", 5},
+		{"let [a, b = a * 2] = [4]; a + b", 12},
+		{"let [a, b = 100] = [4, 5]; a + b", 9},
+		{`let {name, age: years} = {"name": "ann", "age": 30}; name`, "ann"},
+		{`let {name, age: years} = {"name": "ann", "age": 30}; years`, 30},
+		{`let {name = "anon"} = {}; name`, "anon"},
+		{`let {"k": [x, y]} = {"k": [1, 2]}; x + y`, 3},
+		{`let {1: one} = {1: "uno"}; one`, "uno"},
+		{`let [{id}, {id: other}] = [{"id": 1}, {"id": 2}]; id + other`, 3},
+		{"let pair = fn([a, b]) { a - b }; pair([10, 3])", 7},
+		{`let greet = fn({name, greeting = "hi"}) { greeting + " " + name }; greet({"name": "bo"})`, "hi bo"},
+		{"let f = fn(x, [y, ...ys]) { x + y + len(ys) }; f(1, [2, 3, 4])", 5},
+		{"let [a, b] = [1]", "cannot destructure [1]: expected 2 elements, got 1"},
+		{"let [a, b] = [1, 2, 3]", "cannot destructure [1, 2, 3]: expected 2 elements, got 3"},
+		{"let [a, b, ...c] = [1]", "cannot destructure [1]: expected at least 2 elements, got 1"},
+		{"let [a, b = 1] = [1, 2, 3]", "cannot destructure [1, 2, 3]: expected at most 2 elements, got 3"},
+		{"let [a] = 5", "cannot destructure 5: expected an array, got INTEGER"},
+		{"let {a} = [1]", "cannot destructure [1]: expected a hash, got ARRAY"},
+		{`let {name} = {"age": 1}`, "cannot destructure {age: 1}: missing key name"},
+		{"let [[a]] = [1]", "cannot destructure [1]: expected an array, got INTEGER"},
+		{"let [0, a] = [1, 2]", "cannot destructure [1, 2]: expected 0, got 1"},
+		{"let [a = 1 + true] = []", "type mismatch: INTEGER + BOOLEAN"},
+		{"fn([a]) { a }(1)", "cannot destructure 1: expected an array, got INTEGER"},
+		{`match ({"a": 1}) { {b = 2, a} => a + b }`, 3},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case string:
+			switch obj := evaluated.(type) {
+
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q. got=%q", expected, obj.Value)
+				}
+
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q. got=%q", expected, obj.Message)
+				}
+
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+
+			}
+
+		}
+
+	}
+
+}
+
+func TestFunctionParams(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b = 2) { a * b }; f(5)", 10},
+		{"let f = fn(a, b = 2) { a * b }; f(5, 3)", 15},
+		{"let f = fn(a, b = a + 1) { a * b }; f(3)", 12},
+		{"let n = 1; let f = fn(x = n) { x }; n = 7; f()", 7},
+		{"let f = fn(xs = []) { xs = push(xs, 1); len(xs) }; f(); f()", 1},
+		{"let f = fn(first, ...rest) { len(rest) }; f(1)", 0},
+		{"let f = fn(first, ...rest) { rest[1] }; f(1, 2, 3)", 3},
+		{"let f = fn(...all) { len(all) }; f(1, 2, 3, 4)", 4},
+		{"let f = fn(a, b = 10, ...rest) { a + b + len(rest) }; f(1)", 11},
+		{"let f = fn(a, b = 10, ...rest) { a + b + len(rest) }; f(1, 2, 3, 4)", 5},
+		{"let f = fn([x, y] = [1, 2]) { x + y }; f()", 3},
+		{"let add = fn(a, b) { a + b }; add(1)", "add: Got wrong number of args. Expected=2. Got=1"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", "add: Got wrong number of args. Expected=2. Got=3"},
+		{"let f = fn(a, b = 1) { a }; f()", "f: Got wrong number of args. Expected=1 to 2. Got=0"},
+		{"let f = fn(a, ...r) { a }; f()", "f: Got wrong number of args. Expected=at least 1. Got=0"},
+		{"fn(x) { x }()", "anonymous function: Got wrong number of args. Expected=1. Got=0"},
+		{"let f = fn(a = 1 + true) { a }; f()", "type mismatch: INTEGER + BOOLEAN"},
 	}
 
 	for _, tt := range tests {
 
 		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
+
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q. got=%q", expected, errObj.Message)
+			}
+
+		}
 
 	}
 
 }
 
-func TestBangOperator(t *testing.T) {
+func TestKeywordArguments(t *testing.T) {
 
 	tests := []struct {
 		input    string
-		expected bool
+		expected interface{}
 	}{
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
+		{"let f = fn(a, b) { a * 10 + b }; f(b: 2, a: 1)", 12},
+		{"let f = fn(a, b) { a * 10 + b }; f(1, b: 2)", 12},
+		{"let f = fn(a, b = 5, c = 7) { a * 100 + b * 10 + c }; f(1, c: 9)", 159},
+		{"let f = fn(a, b = a) { a + b }; f(a: 4)", 8},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(a: 1)", 1},
+		{"let f = fn([b, c], a) { a + b }; f([1, 2], a: 3)", 4},
+		{"len(range(0, 10, step: 3))", 4},
+		{"len(range(start: 2, stop: 5))", 3},
+		{"len(range(stop: 5))", 5},
+		{"len(range(stop: 10, step: 4))", 3},
+		{"len(range(10, 0, step: -3))", 4},
+		{"len(push([1], value: 2))", 2},
+		{"map([1, 2], func: fn(x) { x * 2 })[1]", 4},
+		{"len(filter(func: fn(x) { x > 1 }, array: [1, 2, 3]))", 2},
+		{"reduce([1, 2], func: fn(acc, x) { acc + x }, initial: 10)", 13},
+		{"[1, 2] |> reduce(initial: 0, func: fn(acc, x) { acc * 10 + x })", 12},
+		{"let f = fn(a, b) { a }; f(1, c: 2)", "f: unknown keyword argument c"},
+		{"let f = fn(a, b) { a }; f(1, a: 2)", "f: got multiple values for argument a"},
+		{"let f = fn(a, b) { a }; f(b: 1, b: 2)", "f: got multiple values for argument b"},
+		{"let f = fn(a, b) { a }; f(b: 1)", "f: missing argument a"},
+		{"let f = fn(a, ...rest) { a }; f(rest: [1])", "f: unknown keyword argument rest"},
+		{"len(x: [1])", "len: does not accept keyword arguments"},
+		{"rational(denom: 2)", "rational: missing argument num"},
+		{"range(start: 5)", "range: missing argument stop"},
+		{"range(step: 2)", "range: missing argument stop"},
+		{"range(5, step: 2)", "range: missing argument stop"},
+		{"fn(x) { x }(y: 1)", "anonymous function: unknown keyword argument y"},
+		{"5(a: 1)", "not a function: INTEGER"},
+		{"let f = fn(a) { a }; f(a: 1 + true)", "type mismatch: INTEGER + BOOLEAN"},
 	}
 
 	for _, tt := range tests {
 
 		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
+
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q. got=%q", expected, errObj.Message)
+			}
+
+		}
 
 	}
+
 }
 
-func TestIfElseExpressions(t *testing.T) {
+func TestPipesAndComposition(t *testing.T) {
 
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{"if( true ) { 10 }", 10},
-		{"if( false ) { 10 }", nil},
-		{"if( 1 ) { 10 }", 10},
-		{"if( 1 < 2 ) { 10 }", 10},
-		{"if( 1 > 2 ) { 10 }", nil},
-		{"if( 1 < 2 ) { 10 } else { 20 }", 10},
-		{"if( 1 > 2 ) { 10 } else { 20 }", 20},
+		{"[1, 2, 3] |> map(fn(x) { x * 2 }) |> sum()", 12},
+		{"[1, 2, 3, 4] |> filter(fn(x) { x % 2 == 0 }) |> len()", 2},
+		{"[1, 2, 3] |> reduce(10, fn(acc, x) { acc + x })", 16},
+		{"let double = fn(x) { x * 2 }; 5 |> double", 10},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"let sub = fn(a, b = 1) { a - b }; 10 |> sub(b: 4)", 6},
+		{"[1, 2] |> push(3) |> last", 3},
+		{"let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; (inc >> double)(3)", 8},
+		{"let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; (double >> inc)(3)", 7},
+		{"let inc = fn(x) { x + 1 }; let f = inc >> inc >> inc; f(0)", 3},
+		{"let inc = fn(x) { x + 1 }; (rest >> len >> inc)([1, 2, 3])", 3},
+		{"let add = fn(a, b) { a + b }; (add >> fn(x) { x * 10 })(1, 2)", 30},
+		{"let inc = fn(x) { x + 1 }; 1 |> inc >> inc", 3},
+		{"([1.5, 2, 1r / 2] |> sum()) == 4.0", true},
+		{"sum([])", 0},
+		{"8 >> 1", 4},
+		{"1 |> 2", "not a function: INTEGER"},
+		{"let inc = fn(x) { x + 1 }; (inc >> 5)", "type mismatch: FUNCTION >> INTEGER"},
+		{"let f = fn(a, b) { a }; (f >> len)(1)", "f: Got wrong number of args. Expected=2. Got=1"},
+		{"[1, 2] |> map(fn(x) { x + true })", "type mismatch: INTEGER + BOOLEAN"},
+		{`sum([1, "a"])`, "sum: No implementation for element type STRING. Expected a number"},
+		{"map(1, len)", "map: No implementation for argument type *object.Integer. Expected=ARRAY"},
+		{"filter([1])", "filter: Got wrong number of args. Expected=2. Got=1"},
 	}
 
 	for _, tt := range tests {
 
 		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
 
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObj(t, evaluated)
+		switch expected := tt.expected.(type) {
+
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q. got=%q", expected, errObj.Message)
+			}
+
 		}
+
 	}
+
 }
 
-func TestReturnStatements(t *testing.T) {
+func TestNullHandlingOperators(t *testing.T) {
 
 	tests := []struct {
 		input    string
-		expected int64
+		expected interface{}
 	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 10; 9;", 10},
-		{`
-			if(10 > 1) {
-				if(10 > 1) {
-					return 10;
-				}
-
-				return 1;
-			}
-		`, 10},
+		{"true ? 1 : 2", 1},
+		{"false ? 1 : 2", 2},
+		{"let x = 5; x > 3 ? x * 2 : x", 10},
+		{"let n = 0; let f = fn() { n += 1 }; true ? 1 : f(); n", 0},
+		{"let sign = fn(x) { x < 0 ? -1 : x == 0 ? 0 : 1 }; sign(-5) + sign(0) * 10 + sign(9) * 100", 99},
+		{`{"a": 1}["b"] ?? 7`, 7},
+		{`{"a": 1}["a"] ?? 7`, 1},
+		{"false ?? 3", false},
+		{"let n = 0; let f = fn() { n += 1 }; 1 ?? f(); n", 0},
+		{`let h = {}; h["x"] ?? h["y"] ?? 3`, 3},
+		{`let h = {"a": {"b": 2}}; h?.["a"]?.["b"]`, 2},
+		{`let h = {"a": {"b": 2}}; h["z"]?.["b"] ?? 9`, 9},
+		{`let h = {}; h["z"]?.["b"]["c"]["d"] ?? 4`, 4},
+		{`let h = {"f": fn(x) { x * 3 }}; h["f"]?.(4)`, 12},
+		{`let h = {}; h["f"]?.(4) ?? 5`, 5},
+		{`let n = 0; let h = {}; h["f"]?.(n += 1); n`, 0},
+		{`let h = {}; 3 |> h["f"]?.() ?? 6`, 6},
+		{`let h = {}; h["z"]["b"]`, "Index operator not supported: NULL[STRING]"},
+		{"1 + true ? 1 : 2", "type mismatch: INTEGER + BOOLEAN"},
+		{`let h = {}; h["f"]?.(1 + true)`, nil},
+		{`let h = {"f": fn(x) { x }}; h["f"]?.(1 + true)`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let h = {}; h["f"](1)`, "not a function: NULL"},
+		{"let e = fn() {}; e() ?? 5", 5},
+		{"let e = fn() { let x = 1 }; e() ?? 5", 5},
+		{"(if (true) { }) ?? 5", 5},
+		{"(if (false) { 1 } else { let x = 1 }) ?? 5", 5},
+		{"let e = fn() {}; e()?.x", nil},
+		{"let e = fn() {}; e()?.[0]", nil},
+		{"let e = fn() {}; e().x", "NULL has no property x"},
+		{"let e = fn() {}; for (x in e()) { }", "cannot iterate over NULL"},
+		{"let e = fn() {}; match (e()) { [a] => a, _ => 6 }", 6},
+		{"let e = fn() {}; let [a] = e()", "cannot destructure null: expected an array, got NULL"},
 	}
 
 	for _, tt := range tests {
 
 		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
 
-	}
+		switch expected := tt.expected.(type) {
 
-}
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
 
-func TestErrorHandling(t *testing.T) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
 
-	tests := []struct {
-		input       string
-		expectedMsg string
-	}{
-		{
-			"5 + true",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"5 + true; 5;",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"-true",
-			"unknown operator: -BOOLEAN",
-		},
-		{
-			"true + false",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"5; true + false; 5;",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"if(10 > 1) { true + false; }",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			`"Hello" - "World"`,
-			"unknown operator: STRING - STRING",
-		},
-		{
-			`
-				if(10 > 1) {
+		case string:
+			errObj, ok := evaluated.(*object.Error)
 
-					if(10 > 1) {
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
 
-						return true + false;
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q. got=%q", expected, errObj.Message)
+			}
 
-					}
+		default:
+			testNullObj(t, evaluated)
 
-				}
+		}
 
-				return 1;
-			`,
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"foobar",
-			"identifier not found: foobar",
-		},
-		{
-			`{"name": "monkey"}[fn(x) { x }]`,
-			`Invalid HashKey: "fn(x) {\nx\n}". Type "FUNCTION" is unsupported.`,
-		},
+	}
+
+}
+
+func TestMemberAccessAndMethods(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let person = {"address": {"city": "Oslo"}}; person.address.city`, "Oslo"},
+		{`let h = {"n": 1}; h.missing`, nil},
+		{`let h = {}; h.a?.b`, nil},
+		{`let h = {}; h?.a.b`, "NULL has no property b"},
+		{`let h = {"a": {"b": 5}}; h?.a?.b`, 5},
+		{`let h = {"n": 1}; h.n = 10; h.n += 5; h["n"]`, 15},
+		{`let h = {}; h.n += 1`, "key not found: n"},
+		{"[1, 2, 3].push(4).len()", 4},
+		{`"hello".len()`, 5},
+		{"[3, 4].first()", 3},
+		{"range(5).len()", 5},
+		{"[1, 2, 3].map(fn(x) { x * x }).sum()", 14},
+		{"[1, 2, 3].reduce(0, fn(a, b) { a + b })", 6},
+		{"range(0, 10).len() * 2", 20},
+		{`let counter = {"inc": fn(x) { x + 1 }}; counter.inc(4)`, 5},
+		{`let h = {"len": fn() { 99 }}; h.len()`, 99},
+		{`let h = {"push": 5}; h.push(1)`, "push: No implementation for argument type *object.Hash. Expected=ARRAY"},
+		{`let h = {"f": fn(x, y) { x - y }}; h.f(y: 1, x: 10)`, 9},
+		{"[1, 2].push(value: 3).last()", 3},
+		{"[4, 5] |> rest().first()", "rest: Got wrong number of args. Expected=1. Got=0"},
+		{"let xs = [1, 2]; ([5] |> xs.push()).len()", 3},
+		{`let h = {}; h.f?.(1)`, nil},
+		{"[1].nope()", "ARRAY has no method nope"},
+		{`{"a": 1}.nope()`, "HASH has no method nope"},
+		{"5.len()", "len: Unsupported argument. expected=STRING. got=INTEGER"},
+		{"[1, 2].len", "ARRAY has no property len"},
+		{"let xs = [1]; xs.n = 2", "Index assignment not supported: ARRAY[STRING]"},
 	}
 
 	for _, tt := range tests {
 
 		evaluated := testEval(tt.input)
 
-		errObj, ok := evaluated.(*object.Error)
+		switch expected := tt.expected.(type) {
 
-		if !ok {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
 
-			t.Errorf("Evaluated result was not error message. Expected=%s. Got=%T(%+v)", tt.expectedMsg, evaluated, evaluated)
-			continue
+		case string:
+			switch obj := evaluated.(type) {
 
-		}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q. got=%q", expected, obj.Value)
+				}
 
-		if errObj.Message != tt.expectedMsg {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q. got=%q", expected, obj.Message)
+				}
 
-			t.Errorf("Wrong error message. Expected=%s. Got=%s", tt.expectedMsg, errObj.Message)
-			continue
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+
+			}
+
+		default:
+			testNullObj(t, evaluated)
 
 		}
 
 	}
+
 }
 
-func TestLetStatements(t *testing.T) {
+func TestHashInspectIsSorted(t *testing.T) {
 
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
-	}
+	input := `{"b": 1, 2: 2, "a": 3, true: 4, 1: 5, false: 6}`
+	expected := `{false: 6, true: 4, 1: 5, 2: 2, a: 3, b: 1}`
 
-	for _, tt := range tests {
+	for i := 0; i < 10; i++ {
 
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		evaluated := testEval(input)
+
+		if evaluated.Inspect() != expected {
+			t.Fatalf("wrong Inspect output. expected=%q. got=%q", expected, evaluated.Inspect())
+		}
 
 	}
 
@@ -397,11 +1495,22 @@ func TestBuiltinFns(t *testing.T) {
 		{`len("four")`, 4},
 		{`len('four')`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
 		{`len([1, 2])`, 2},
 		{`first([1, 2, 3])`, 1},
 		{`last([1, 2, 3])`, 3},
 		{`rest([1, 2, 3])[0]`, 2},
 		{`push([], 1)[0]`, 1},
+		{`float(3)`, 3.0},
+		{`float("2.5")`, 2.5},
+		{`int(3.99)`, 3},
+		{`int(-3.99)`, -3},
+		{`int("42")`, 42},
+		{`int(float(7) / 2)`, 3},
+		{`float("abc")`, `float: Could not parse "abc" as float`},
+		{`int(2.0 ** 100) == 2 ** 100`, true},
+		{`int(float("nan"))`, "int: Cannot convert NaN to an integer"},
 		{`len(1)`, "len: Unsupported argument. expected=STRING. got=INTEGER"},
 		{`len("one", "two")`, "len: wrong number of args. expected=1. got=2"},
 	}
@@ -414,6 +1523,10 @@ func TestBuiltinFns(t *testing.T) {
 
 		case int:
 			testIntegerObject(t, eval, int64(expected))
+		case float64:
+			testFloatObject(t, eval, expected)
+		case bool:
+			testBooleanObject(t, eval, expected)
 		case string:
 			errObj, ok := eval.(*object.Error)
 
@@ -659,6 +1772,28 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+
+	res, ok := obj.(*object.Float)
+
+	if !ok {
+
+		t.Errorf("object is incorrect type. Expected=Float. Got=%T", obj)
+		return false
+
+	}
+
+	if res.Value != expected {
+
+		t.Errorf("object has incorrect value. Expected=%g. Got=%g", expected, res.Value)
+		return false
+
+	}
+
+	return true
+
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 
 	res, ok := obj.(*object.Boolean)
@@ -680,3 +1815,63 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 
 }
+
+func TestStringIndexExpressions(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello"[0]`, "h"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[2]`, "l"},
+		{`let s = "日本語"; s[len(s) - 1]`, "語"},
+		{`"héllo"[5]`, nil},
+		{`"héllo"[-1]`, nil},
+	}
+
+	for _, tt := range tests {
+
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+
+		if !ok {
+			testNullObj(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != expected {
+			t.Errorf("String has incorrect value. Expected=%q. Got=%q", expected, str.Value)
+		}
+
+	}
+
+}
+
+func TestBytesBuiltin(t *testing.T) {
+
+	evaluated := testEval(`bytes("aé")`)
+	arr, ok := evaluated.(*object.Array)
+
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []int64{97, 195, 169}
+
+	if len(arr.Elements) != len(expected) {
+		t.Fatalf("Array has incorrect number of elements. Expected=%d. Got=%d", len(expected), len(arr.Elements))
+	}
+
+	for i, b := range expected {
+		testIntegerObject(t, arr.Elements[i], b)
+	}
+
+}
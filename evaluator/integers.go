@@ -0,0 +1,229 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/Sheep42/Monkey-Lang/object"
+)
+
+// Integers are int64 backed *object.Integer values until a result would
+// overflow, at which point they are promoted to a math/big backed
+// *object.BigInteger. Results are always passed through normalizeInteger, so a
+// BigInteger never holds a value that fits in an int64.
+
+// maxIntegerBits caps the size of results that can grow without bound, such as
+// 1 << n and a ** n. Without it a single expression could exhaust memory, which
+// kills the host process before Eval's recover gets a chance to run.
+const maxIntegerBits = 1 << 20
+
+func evalInfixBigIntegerExpression(operator string, left, right object.Object) object.Object {
+
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+	res := new(big.Int)
+
+	switch operator {
+
+	case "+":
+		return normalizeInteger(res.Add(leftVal, rightVal))
+
+	case "-":
+		return normalizeInteger(res.Sub(leftVal, rightVal))
+
+	case "*":
+		return normalizeInteger(res.Mul(leftVal, rightVal))
+
+	case "/", "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+
+		// Quo and Rem truncate toward zero like the int64 operators
+		if operator == "/" {
+			return normalizeInteger(res.Quo(leftVal, rightVal))
+		}
+
+		return normalizeInteger(res.Rem(leftVal, rightVal))
+
+	case "**":
+		if rightVal.Sign() < 0 {
+
+			if leftVal.Sign() == 0 {
+				return newError("division by zero")
+			}
+
+			return &object.Float{Value: math.Pow(toFloat(left), toFloat(right))}
+
+		}
+
+		if !powFits(leftVal, rightVal) {
+			return newError("integer too large: %s ** %s exceeds %d bits", leftVal, rightVal, maxIntegerBits)
+		}
+
+		return normalizeInteger(res.Exp(leftVal, rightVal, nil))
+
+	case "&":
+		return normalizeInteger(res.And(leftVal, rightVal))
+
+	case "|":
+		return normalizeInteger(res.Or(leftVal, rightVal))
+
+	case "^":
+		return normalizeInteger(res.Xor(leftVal, rightVal))
+
+	case "<<", ">>":
+		if rightVal.Sign() < 0 {
+			return newError("negative shift count: %s", rightVal)
+		}
+
+		// shifting right by at least the bit length leaves only the sign
+		if operator == ">>" {
+
+			if !rightVal.IsInt64() || rightVal.Int64() >= int64(leftVal.BitLen()) {
+				return normalizeInteger(res.Rsh(leftVal, uint(leftVal.BitLen())))
+			}
+
+			return normalizeInteger(res.Rsh(leftVal, uint(rightVal.Int64())))
+
+		}
+
+		if leftVal.Sign() == 0 {
+			return &object.Integer{Value: 0}
+		}
+
+		if !rightVal.IsInt64() || rightVal.Int64() > int64(maxIntegerBits-leftVal.BitLen()) {
+			return newError("integer too large: %s << %s exceeds %d bits", leftVal, rightVal, maxIntegerBits)
+		}
+
+		return normalizeInteger(res.Lsh(leftVal, uint(rightVal.Int64())))
+
+	case "<":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) < 0)
+
+	case ">":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) > 0)
+
+	case "<=":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) <= 0)
+
+	case ">=":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) >= 0)
+
+	case "==":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) == 0)
+
+	case "!=":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) != 0)
+
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+}
+
+// powFits reports whether base ** exp, for a non-negative exp, stays within
+// maxIntegerBits. base.BitLen() * exp is an upper bound on the result's size.
+func powFits(base, exp *big.Int) bool {
+
+	// 0, 1 and -1 stay small however large the exponent is
+	if base.BitLen() <= 1 {
+		return true
+	}
+
+	return exp.IsInt64() && exp.Int64() <= int64(maxIntegerBits/base.BitLen())
+
+}
+
+// normalizeInteger returns an Integer when v fits in an int64 and a BigInteger
+// otherwise. v must not be modified afterwards.
+func normalizeInteger(v *big.Int) object.Object {
+
+	if v.IsInt64() {
+		return &object.Integer{Value: v.Int64()}
+	}
+
+	return &object.BigInteger{Value: v}
+
+}
+
+// toBigInt converts an Integer or BigInteger to a *big.Int. The result of a
+// BigInteger is shared, so callers must not modify it.
+func toBigInt(obj object.Object) *big.Int {
+
+	if i, ok := obj.(*object.Integer); ok {
+		return big.NewInt(i.Value)
+	}
+
+	return obj.(*object.BigInteger).Value
+
+}
+
+// addInt64 returns a + b, and false if the sum overflows
+func addInt64(a, b int64) (int64, bool) {
+
+	res := a + b
+
+	// overflow flips the sign away from that of both operands
+	return res, (a^res)&(b^res) >= 0
+
+}
+
+// subInt64 returns a - b, and false if the difference overflows
+func subInt64(a, b int64) (int64, bool) {
+
+	res := a - b
+
+	return res, (a^b)&(a^res) >= 0
+
+}
+
+// mulInt64 returns a * b, and false if the product overflows
+func mulInt64(a, b int64) (int64, bool) {
+
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	res := a * b
+
+	if res/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+
+	return res, true
+
+}
+
+// powInt64 raises base to a non-negative exponent by repeated squaring, and
+// returns false if the result overflows
+func powInt64(base, exp int64) (int64, bool) {
+
+	res := int64(1)
+	ok := true
+
+	for exp > 0 {
+
+		if exp&1 == 1 {
+
+			if res, ok = mulInt64(res, base); !ok {
+				return 0, false
+			}
+
+		}
+
+		exp >>= 1
+
+		if exp > 0 {
+
+			if base, ok = mulInt64(base, base); !ok {
+				return 0, false
+			}
+
+		}
+
+	}
+
+	return res, true
+
+}
@@ -0,0 +1,259 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Sheep42/Monkey-Lang/ast"
+	"github.com/Sheep42/Monkey-Lang/object"
+)
+
+// Patterns are used both to test values, in match arms, and to take them
+// apart, in let statements and function parameters. bindPattern serves both:
+// match treats a mismatch as "try the next arm", destructuring turns it into
+// an error.
+
+// evalMatchExpression evaluates the body of the first arm whose pattern
+// matches the subject and whose guard, if any, is truthy. Each arm gets its own
+// environment, so names bound by an arm that fails to match never leak.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+
+	subject := eval(me.Subject, env)
+
+	if isErrorOrSignal(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+
+		armEnv := object.NewEnclosedEnvironment(env)
+
+		mismatch, err := bindPattern(arm.Pattern, subject, armEnv)
+
+		if err != nil {
+			return err
+		}
+
+		if mismatch != "" {
+			continue
+		}
+
+		if arm.Guard != nil {
+
+			guard := eval(arm.Guard, armEnv)
+
+			if isErrorOrSignal(guard) {
+				return guard
+			}
+
+			if !isTruthy(guard) {
+				continue
+			}
+
+		}
+
+		return eval(arm.Body, armEnv)
+
+	}
+
+	return newError("match: no arm matched %s", subject.Inspect())
+
+}
+
+// destructure binds the names in pattern to the matching parts of val, or
+// returns an error describing why val does not have the pattern's shape
+func destructure(pattern ast.Pattern, val object.Object, env *object.Environment) object.Object {
+
+	mismatch, err := bindPattern(pattern, val, env)
+
+	if err != nil {
+		return err
+	}
+
+	if mismatch != "" {
+		return newError("cannot destructure %s: %s", val.Inspect(), mismatch)
+	}
+
+	return nil
+
+}
+
+// bindPattern matches val against pattern, binding any names the pattern
+// introduces in env. It returns a description of the first mismatch, or "" if
+// val matches. The error is non-nil only if evaluating a literal or default
+// value in the pattern failed.
+func bindPattern(pattern ast.Pattern, val object.Object, env *object.Environment) (string, object.Object) {
+
+	switch pattern := pattern.(type) {
+
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, val)
+		}
+
+		return "", nil
+
+	case *ast.LiteralPattern:
+		return matchLiteral(pattern.Value, val, env)
+
+	case *ast.DefaultPattern:
+		return bindPattern(pattern.Pattern, val, env)
+
+	case *ast.ArrayPattern:
+		return bindArrayPattern(pattern, val, env)
+
+	case *ast.HashPattern:
+		return bindHashPattern(pattern, val, env)
+
+	}
+
+	return "", newError("unknown pattern: %s", pattern.String())
+
+}
+
+func bindArrayPattern(pattern *ast.ArrayPattern, val object.Object, env *object.Environment) (string, object.Object) {
+
+	array, ok := val.(*object.Array)
+
+	if !ok {
+		return fmt.Sprintf("expected an array, got %s", val.Type()), nil
+	}
+
+	// elements with defaults may be missing, as long as everything before them
+	// is present
+	required := 0
+
+	for i, el := range pattern.Elements {
+
+		if _, ok := el.(*ast.DefaultPattern); !ok {
+			required = i + 1
+		}
+
+	}
+
+	n, size := len(pattern.Elements), len(array.Elements)
+
+	switch {
+
+	case pattern.Rest == nil && required == n && size != n:
+		return fmt.Sprintf("expected %d elements, got %d", n, size), nil
+
+	case size < required:
+		return fmt.Sprintf("expected at least %d elements, got %d", required, size), nil
+
+	case pattern.Rest == nil && size > n:
+		return fmt.Sprintf("expected at most %d elements, got %d", n, size), nil
+
+	}
+
+	for i, el := range pattern.Elements {
+
+		var mismatch string
+		var err object.Object
+
+		if i < size {
+			mismatch, err = bindPattern(el, array.Elements[i], env)
+		} else {
+			mismatch, err = bindDefault(el.(*ast.DefaultPattern), env)
+		}
+
+		if mismatch != "" || err != nil {
+			return mismatch, err
+		}
+
+	}
+
+	if pattern.Rest != nil && pattern.Rest.Name != nil {
+
+		rest := []object.Object{}
+
+		if size > n {
+			rest = make([]object.Object, size-n)
+			copy(rest, array.Elements[n:])
+		}
+
+		env.Set(pattern.Rest.Name.Value, &object.Array{Elements: rest})
+
+	}
+
+	return "", nil
+
+}
+
+func bindHashPattern(pattern *ast.HashPattern, val object.Object, env *object.Environment) (string, object.Object) {
+
+	hash, ok := val.(*object.Hash)
+
+	if !ok {
+		return fmt.Sprintf("expected a hash, got %s", val.Type()), nil
+	}
+
+	for _, pair := range pattern.Pairs {
+
+		key := eval(pair.Key, env)
+
+		if isErrorOrSignal(key) {
+			return "", key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+
+		if !ok {
+			return "", newError("Invalid HashKey: %q. Type %q is unsupported.", key.Inspect(), key.Type())
+		}
+
+		var mismatch string
+		var err object.Object
+
+		if found, ok := hash.Pairs[hashKey.HashKey()]; ok {
+			mismatch, err = bindPattern(pair.Value, found.Value, env)
+		} else if def, ok := pair.Value.(*ast.DefaultPattern); ok {
+			mismatch, err = bindDefault(def, env)
+		} else {
+			mismatch = fmt.Sprintf("missing key %s", key.Inspect())
+		}
+
+		if mismatch != "" || err != nil {
+			return mismatch, err
+		}
+
+	}
+
+	return "", nil
+
+}
+
+// bindDefault binds the default value of a missing element. Defaults are
+// evaluated each time they are needed, and can see names bound earlier in the
+// same pattern.
+func bindDefault(pattern *ast.DefaultPattern, env *object.Environment) (string, object.Object) {
+
+	val := eval(pattern.Default, env)
+
+	if isErrorOrSignal(val) {
+		return "", val
+	}
+
+	return bindPattern(pattern.Pattern, val, env)
+
+}
+
+// matchLiteral compares val with a literal using the same equality as hash
+// keys, so 1 matches 1 but not 1.0 or "1"
+func matchLiteral(lit ast.Expression, val object.Object, env *object.Environment) (string, object.Object) {
+
+	want := eval(lit, env)
+
+	if isErrorOrSignal(want) {
+		return "", want
+	}
+
+	wantKey, ok := want.(object.Hashable)
+	valKey, valOk := val.(object.Hashable)
+
+	if !ok || !valOk || wantKey.HashKey() != valKey.HashKey() {
+		return fmt.Sprintf("expected %s, got %s", want.Inspect(), val.Inspect()), nil
+	}
+
+	return "", nil
+
+}
@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"math/big"
+
+	"github.com/Sheep42/Monkey-Lang/object"
+)
+
+// evalInfixRationalExpression handles exact arithmetic where at least one
+// operand is a Rational and the other is a Rational or an integer
+func evalInfixRationalExpression(operator string, left, right object.Object) object.Object {
+
+	leftVal := toBigRat(left)
+	rightVal := toBigRat(right)
+	res := new(big.Rat)
+
+	switch operator {
+
+	case "+":
+		return &object.Rational{Value: res.Add(leftVal, rightVal)}
+
+	case "-":
+		return &object.Rational{Value: res.Sub(leftVal, rightVal)}
+
+	case "*":
+		return &object.Rational{Value: res.Mul(leftVal, rightVal)}
+
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+
+		return &object.Rational{Value: res.Quo(leftVal, rightVal)}
+
+	case "**":
+		switch right.(type) {
+
+		case *object.Integer, *object.BigInteger:
+			return ratPow(leftVal, toBigInt(right))
+
+		}
+
+		return newError("unsupported exponent: %s ** %s. Expected an INTEGER exponent", left.Type(), right.Type())
+
+	case "<":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) < 0)
+
+	case ">":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) > 0)
+
+	case "<=":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) <= 0)
+
+	case ">=":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) >= 0)
+
+	case "==":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) == 0)
+
+	case "!=":
+		return nativeBoolToBooleanObj(leftVal.Cmp(rightVal) != 0)
+
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+}
+
+// ratPow raises base to an integer power, which keeps the result exact. Like
+// integer powers, the numerator and denominator are limited to maxIntegerBits.
+func ratPow(base *big.Rat, exp *big.Int) object.Object {
+
+	e := new(big.Int).Abs(exp)
+
+	if !powFits(base.Num(), e) || !powFits(base.Denom(), e) {
+		return newError("rational too large: %s ** %s exceeds %d bits", base, exp, maxIntegerBits)
+	}
+
+	if exp.Sign() < 0 {
+
+		if base.Sign() == 0 {
+			return newError("division by zero")
+		}
+
+		base = new(big.Rat).Inv(base)
+
+	}
+
+	num := new(big.Int).Exp(base.Num(), e, nil)
+	denom := new(big.Int).Exp(base.Denom(), e, nil)
+
+	return &object.Rational{Value: new(big.Rat).SetFrac(num, denom)}
+
+}
+
+// toBigRat converts an Integer, BigInteger or Rational to a *big.Rat. The
+// result of a Rational is shared, so callers must not modify it.
+func toBigRat(obj object.Object) *big.Rat {
+
+	if r, ok := obj.(*object.Rational); ok {
+		return r.Value
+	}
+
+	return new(big.Rat).SetInt(toBigInt(obj))
+
+}
@@ -1,42 +1,103 @@
 //lexer/lexer.go
 package lexer
 
-import "github.com/Sheep42/Monkey-Lang/token"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Sheep42/Monkey-Lang/token"
+)
+
+//Mode controls optional Lexer behaviour
+type Mode uint
+
+const (
+	//KeepComments attaches comments to neighbouring tokens as trivia instead of discarding them
+	KeepComments Mode = 1 << iota
+)
+
+//Error describes a problem found while scanning the input
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
 
 type Lexer struct {
-	input        string //The input
-	position     int    //current position in input (points to char)
-	readPosition int    //reading pos in input (after the current position)
-	ch           byte   //the current char being examined
+	file         *token.File //The file the input belongs to, used to resolve positions
+	input        string      //The input
+	position     int         //current position in input (points to char)
+	readPosition int         //reading pos in input (after the current position)
+	ch           rune        //the current char being examined, decoded from UTF-8
+	mode         Mode        //optional behaviour, see Mode
+	errors       []Error     //problems found so far
 }
 
 /** Lexer Methods **/
-//Reads line char by char and increments the Lexer position
+//Reads line char by char and increments the Lexer position. Positions are byte offsets,
+//a char may be several bytes long.
 func (l *Lexer) readChar() {
+	//Record the start of every new line so positions can be resolved later
+	if l.ch == '\n' {
+		l.file.AddLine(l.readPosition)
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		l.position = len(l.input)
+		l.readPosition = len(l.input)
+
+		return
+	}
+
+	ch, width := rune(l.input[l.readPosition]), 1
+
+	if ch >= utf8.RuneSelf {
+		ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
+
+		if ch == utf8.RuneError && width == 1 {
+			l.error(l.readPosition, "invalid UTF-8 encoding")
+		}
 	}
 
+	l.ch = ch
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
 //Returns the next character in input (without moving the current pos)
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+
+	return ch
+}
+
+//Returns the next token, skipping whitespace and comments. In KeepComments mode
+//comments are attached to the token: those on the same line after it as Trailing,
+//all others as Leading on the token that follows them.
+func (l *Lexer) NextToken() token.Token {
+	leading := l.skipTrivia()
+
+	tok := l.scanToken()
+
+	if l.mode&KeepComments != 0 {
+		tok.Leading = leading
+		tok.Trailing = l.readTrailingComments()
+	}
+
+	return tok
 }
 
 //Checks current character and returns a token for it, Returns ILLEGAL for non-mapped/non-accepted chars
-func (l *Lexer) NextToken() token.Token {
+func (l *Lexer) scanToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	start := l.position
 
 	switch l.ch {
 	case '=':
@@ -51,6 +112,8 @@ func (l *Lexer) NextToken() token.Token {
 				Type:    token.EQ,
 				Literal: literal,
 			}
+		} else if l.peekChar() == '>' {
+			tok = l.readTwoCharToken(token.ARROW)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -59,17 +122,73 @@ func (l *Lexer) NextToken() token.Token {
 	case ':':
 		tok = newToken(token.COLON, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.readOperator(token.PLUS, '=', token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.readOperator(token.MINUS, '=', token.MINUS_ASSIGN)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.readOperator(token.SLASH, '=', token.SLASH_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		switch l.peekChar() {
+		case '*':
+			tok = l.readTwoCharToken(token.POWER)
+		case '=':
+			tok = l.readTwoCharToken(token.ASTERISK_ASSIGN)
+		default:
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		tok = l.readOperator(token.PERCENT, '=', token.PERCENT_ASSIGN)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.readTwoCharToken(token.SHL)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.readTwoCharToken(token.SHR)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		tok = l.readOperator(token.BIT_AND, '&', token.AND)
+	case '|':
+		switch l.peekChar() {
+		case '|':
+			tok = l.readTwoCharToken(token.OR)
+		case '>':
+			tok = l.readTwoCharToken(token.PIPE)
+		default:
+			tok = newToken(token.BIT_OR, l.ch)
+		}
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			tok = l.readTwoCharToken(token.NULL_COALESCE)
+		case '.':
+			tok = l.readTwoCharToken(token.OPTIONAL_CHAIN)
+		default:
+			tok = newToken(token.QUESTION, l.ch)
+		}
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			l.readChar()
+			l.readChar()
+
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -100,6 +219,9 @@ func (l *Lexer) NextToken() token.Token {
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString(l.ch)
+	case '`':
+		tok.Type = token.STRING
+		tok.Literal = l.readRawString()
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -110,16 +232,15 @@ func (l *Lexer) NextToken() token.Token {
 	default:
 		if isDigit(l.ch) {
 			//Lexes numbers
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
 
-			return tok
+			return l.withPos(tok, start)
 		} else if isLetter(l.ch) {
 			//Lexes keywords/user-defined identifiers
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 
-			return tok
+			return l.withPos(tok, start)
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
@@ -127,49 +248,354 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.readChar()
 
+	return l.withPos(tok, start)
+}
+
+//Stamps a token with its start and end positions
+func (l *Lexer) withPos(tok token.Token, start int) token.Token {
+	tok.Pos = l.file.Pos(start)
+	tok.End = l.file.Pos(l.position)
+
 	return tok
 }
 
+//Returns the file used to resolve token positions
+func (l *Lexer) File() *token.File {
+	return l.file
+}
+
+//Sets optional lexer behaviour, see Mode
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
+//Returns the problems found while scanning so far
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
+//Records a problem found at byte offset
+func (l *Lexer) error(offset int, msg string) {
+	l.errors = append(l.errors, Error{Pos: l.file.Pos(offset), Msg: msg})
+}
+
 //Reads an identifier and advances Lexer pos until a non-legal/whitespace character is encountered
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) || unicode.IsMark(l.ch) {
 		l.readChar()
 	}
 
 	return l.input[position:l.position]
 }
 
-//Reads a number and advances Lexer pos until a non-number char is encountered
-func (l *Lexer) readNumber() string {
+//Lexes an operator that may be one or two chars long. If the next char is next
+//both are combined into a token of type double, otherwise the current char
+//alone becomes a token of type single.
+func (l *Lexer) readOperator(single token.TokenType, next rune, double token.TokenType) token.Token {
+	if l.peekChar() != next {
+		return newToken(single, l.ch)
+	}
+
+	return l.readTwoCharToken(double)
+}
+
+//Combines the current and next chars into a single token
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+
+	l.readChar()
+
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+//Reads a number and advances Lexer pos until a non-number char is encountered.
+//Numbers with a fraction (3.14) or an exponent (1e-9) are FLOATs, all others INTs.
+//Digits may be separated by '_', and 0x, 0o and 0b prefixes select another base.
+//The spelling is checked by the parser, so malformed literals stay in one piece.
+//A decimal number followed by an r suffix (1r, 0.25r) is an exact RAT.
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
 
-	for isDigit(l.ch) {
+	if l.ch == '0' && strings.ContainsRune("xXoObB", l.peekChar()) {
 		l.readChar()
+		l.readChar()
+
+		for l.ch < utf8.RuneSelf && (isLetter(l.ch) || isDigit(l.ch)) {
+			l.readChar()
+		}
+
+		return tokenType, l.input[position:l.position]
 	}
 
-	return l.input[position:l.position]
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.atExponent() {
+		tokenType = token.FLOAT
+
+		l.readChar()
+
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+
+		l.readDigits()
+	}
+
+	if next := l.peekChar(); l.ch == 'r' && !isLetter(next) && !isDigit(next) {
+		tokenType = token.RAT
+
+		l.readChar()
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+//Advances Lexer pos past a run of digits and '_' separators
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
 }
 
-func (l *Lexer) readString(quoteCh byte) string {
+//Checks if the current char starts an exponent such as e5, e+5 or E-5
+func (l *Lexer) atExponent() bool {
+	if l.ch != 'e' && l.ch != 'E' {
+		return false
+	}
+
+	rest := l.input[l.readPosition:]
 
-	pos := l.position + 1
+	if len(rest) > 0 && (rest[0] == '+' || rest[0] == '-') {
+		rest = rest[1:]
+	}
+
+	return len(rest) > 0 && isDigit(rune(rest[0]))
+}
+
+//Reads a quoted string, decoding escape sequences. A quoted string has to be closed
+//on the same line, use a `raw string` for multi-line text.
+func (l *Lexer) readString(quoteCh rune) string {
+
+	start := l.position
+
+	var out strings.Builder
 
 	for {
 
 		l.readChar()
 
-		if l.ch == quoteCh || l.ch == 0 {
+		switch l.ch {
+		case quoteCh:
+			return out.String()
+		case '\n', 0:
+			l.error(start, "unterminated string literal")
+			return out.String()
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+		}
+
+	}
+
+}
+
+//Decodes the escape sequence starting at the current backslash into out
+func (l *Lexer) readEscape(out *strings.Builder) {
+
+	start := l.position
+
+	//Leave line ends alone so the string is reported as unterminated
+	if l.peekChar() == '\n' || l.peekChar() == 0 {
+		return
+	}
+
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '0':
+		out.WriteByte(0)
+	case '\\', '"', '\'':
+		out.WriteRune(l.ch)
+	case 'x':
+		value, digits := l.readHexDigits(2)
+
+		if digits != 2 || value > 0x7F {
+			l.error(start, "invalid \\x escape: expected two hex digits no greater than 7F")
+			return
+		}
+
+		out.WriteByte(byte(value))
+	case 'u':
+		if l.peekChar() != '{' {
+			l.error(start, "invalid \\u escape: expected \\u{...}")
+			return
+		}
+
+		l.readChar()
+
+		value, digits := l.readHexDigits(6)
+
+		if digits == 0 || l.peekChar() != '}' {
+			l.error(start, "invalid \\u escape: expected one to six hex digits followed by }")
+			return
+		}
+
+		l.readChar()
+
+		if !utf8.ValidRune(value) {
+			l.error(start, fmt.Sprintf("invalid \\u escape: U+%X is not a valid code point", value))
+			return
+		}
+
+		out.WriteRune(value)
+	default:
+		l.error(start, fmt.Sprintf("unknown escape sequence \\%c", l.ch))
+
+		out.WriteByte('\\')
+		out.WriteRune(l.ch)
+	}
+
+}
+
+//Reads up to max hex digits following the current character
+func (l *Lexer) readHexDigits(max int) (value rune, digits int) {
+
+	for digits < max && isHexDigit(l.peekChar()) {
+
+		l.readChar()
+
+		value = value*16 + rune(hexValue(l.ch))
+		digits++
+
+	}
+
+	return value, digits
+
+}
+
+//Reads a `raw string`. No escapes are processed and it may span several lines.
+func (l *Lexer) readRawString() string {
+
+	start := l.position
+
+	for {
+
+		l.readChar()
+
+		if l.ch == '`' {
+			break
+		}
+
+		if l.ch == 0 {
+			l.error(start, "unterminated raw string literal")
 			break
 		}
 
 	}
 
-	return l.input[pos:l.position]
+	//Carriage returns are dropped so the value doesn't depend on the file's line endings
+	return strings.ReplaceAll(l.input[start+1:l.position], "\r", "")
 
 }
 
+//Eats whitespace and comments, returning the comments when they are being kept
+func (l *Lexer) skipTrivia() []token.Comment {
+	var comments []token.Comment
+
+	for {
+		l.skipWhitespace()
+
+		if !l.atComment() {
+			return comments
+		}
+
+		comment := l.readComment()
+
+		if l.mode&KeepComments != 0 {
+			comments = append(comments, comment)
+		}
+	}
+}
+
+//Reads the comments following a token on the same line
+func (l *Lexer) readTrailingComments() []token.Comment {
+	var comments []token.Comment
+
+	for {
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+			l.readChar()
+		}
+
+		if !l.atComment() {
+			return comments
+		}
+
+		comments = append(comments, l.readComment())
+	}
+}
+
+//Checks if the current character starts a comment
+func (l *Lexer) atComment() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+//Reads a // line comment or a /* block comment */. Block comments nest.
+func (l *Lexer) readComment() token.Comment {
+	start := l.position
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	} else {
+		l.readChar()
+		l.readChar()
+
+		for depth := 1; depth > 0; {
+			switch {
+			case l.ch == 0:
+				l.error(start, "unterminated block comment")
+				depth = 0
+			case l.ch == '/' && l.peekChar() == '*':
+				l.readChar()
+				l.readChar()
+				depth++
+			case l.ch == '*' && l.peekChar() == '/':
+				l.readChar()
+				l.readChar()
+				depth--
+			default:
+				l.readChar()
+			}
+		}
+	}
+
+	text := strings.TrimRight(l.input[start:l.position], "\r")
+
+	return token.Comment{
+		Text: text,
+		Pos:  l.file.Pos(start),
+		End:  l.file.Pos(start + len(text)),
+	}
+}
+
 //Eats whitespace
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
@@ -178,30 +604,57 @@ func (l *Lexer) skipWhitespace() {
 }
 
 /** Utility Functions **/
-//Create a new Lexer
+//Create a new Lexer for an anonymous input with its own position space
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFile(token.NewFileSet().AddFile("", len(input)), input)
+}
+
+//Create a new Lexer for input registered as file in a FileSet
+func NewWithFile(file *token.File, input string) *Lexer {
+	l := &Lexer{file: file, input: input}
 
 	// sets up the first char
 	l.readChar()
 
+	//A leading byte order mark is not part of the source
+	if l.ch == '\uFEFF' {
+		l.readChar()
+	}
+
 	return l
 }
 
 //Creates a new Token
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(ch),
 	}
 }
 
-//Checks if a character is a letter
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || '0' <= ch && ch <= '9'
+//Checks if a character can start an identifier: any Unicode letter or '_'
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
 //Checks if a character is a number
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+//Checks if a character is a hexadecimal digit
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+//Returns the value of a hexadecimal digit
+func hexValue(ch rune) int {
+	switch {
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'F':
+		return int(ch-'A') + 10
+	default:
+		return int(ch - '0')
+	}
+}
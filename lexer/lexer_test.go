@@ -17,7 +17,7 @@ func TestNextToken(t *testing.T) {
 		};
 
 		let result = add(five, ten);
-		!-/*5;
+		!-/ *5;
 		5 < 10 > 5;
 
 		if(5 < 10) {
@@ -150,3 +150,618 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + \"ab\";\n"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+		expectedOffset int
+		expectedEnd    int
+	}{
+		{token.LET, 1, 1, 0, 3},
+		{token.IDENT, 1, 5, 4, 5},
+		{token.ASSIGN, 1, 7, 6, 7},
+		{token.INT, 1, 9, 8, 9},
+		{token.SEMI, 1, 10, 9, 10},
+		{token.IDENT, 2, 3, 13, 14},
+		{token.PLUS, 2, 5, 15, 16},
+		{token.STRING, 2, 7, 17, 21},
+		{token.SEMI, 2, 11, 21, 22},
+		{token.EOF, 3, 1, 23, 23},
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("test.mk", len(input))
+	l := NewWithFile(file, input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - wrong tokentype. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		pos := fset.Position(tok.Pos)
+
+		if pos.Filename != "test.mk" {
+			t.Errorf("tests[%d] - wrong filename. expected=%q, got=%q", i, "test.mk", pos.Filename)
+		}
+
+		if pos.Line != tt.expectedLine || pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong line:column. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, pos.Line, pos.Column)
+		}
+
+		if pos.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - wrong offset. expected=%d, got=%d", i, tt.expectedOffset, pos.Offset)
+		}
+
+		if end := file.Offset(tok.End); end != tt.expectedEnd {
+			t.Errorf("tests[%d] - wrong end offset. expected=%d, got=%d", i, tt.expectedEnd, end)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading line comment
+		let x = 5; // trailing
+		/* block /* nested */ still comment */ x / 2;
+		x /* inline */ * 3`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMI, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMI, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK, "*"},
+		{token.INT, "3"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if len(tok.Leading) != 0 || len(tok.Trailing) != 0 {
+			t.Fatalf("tests[%d] - comments kept without KeepComments mode", i)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("Unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestCommentTrivia(t *testing.T) {
+	input := "// about x\n/* more */\nlet x = 5; // five\n1 /* one */ + 2"
+
+	l := New(input)
+	l.SetMode(KeepComments)
+
+	tests := []struct {
+		expectedLiteral  string
+		expectedLeading  []string
+		expectedTrailing []string
+	}{
+		{"let", []string{"// about x", "/* more */"}, nil},
+		{"x", nil, nil},
+		{"=", nil, nil},
+		{"5", nil, nil},
+		{";", nil, []string{"// five"}},
+		{"1", nil, []string{"/* one */"}},
+		{"+", nil, nil},
+		{"2", nil, nil},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong literal. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if got := commentTexts(tok.Leading); !equalStrings(got, tt.expectedLeading) {
+			t.Errorf("tests[%d] - wrong leading comments. expected=%q, got=%q", i, tt.expectedLeading, got)
+		}
+
+		if got := commentTexts(tok.Trailing); !equalStrings(got, tt.expectedTrailing) {
+			t.Errorf("tests[%d] - wrong trailing comments. expected=%q, got=%q", i, tt.expectedTrailing, got)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("let x = 1; /* never /* closed */")
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	errors := l.Errors()
+
+	if len(errors) != 1 {
+		t.Fatalf("Wrong number of errors. expected=1, got=%d", len(errors))
+	}
+
+	if errors[0].Msg != "unterminated block comment" {
+		t.Errorf("Wrong error message. got=%q", errors[0].Msg)
+	}
+
+	if pos := l.File().Position(errors[0].Pos); pos.Column != 12 {
+		t.Errorf("Wrong error column. expected=12, got=%d", pos.Column)
+	}
+}
+
+func commentTexts(comments []token.Comment) []string {
+	var texts []string
+
+	for _, c := range comments {
+		texts = append(texts, c.Text)
+	}
+
+	return texts
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"cr\r"`, "cr\r"},
+		{`"back\\slash"`, `back\slash`},
+		{`"say \"hi\""`, `say "hi"`},
+		{`'it\'s'`, "it's"},
+		{`"nul\0"`, "nul\x00"},
+		{`"\x41\x7e"`, "A~"},
+		{`"caf\u{e9}"`, "café"},
+		{`"\u{1F600}"`, "\U0001F600"},
+		{"`raw \\n \"string\"`", `raw \n "string"`},
+		{"`multi\r\nline`", "multi\nline"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("tests[%d] - wrong tokentype. expected=%q, got=%q", i, token.STRING, tok.Type)
+		}
+
+		if tok.Literal != tt.expected {
+			t.Errorf("tests[%d] - wrong literal. expected=%q, got=%q", i, tt.expected, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("tests[%d] - unexpected errors: %v", i, l.Errors())
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedMsg    string
+		expectedColumn int
+	}{
+		{`let s = "open`, "unterminated string literal", 9},
+		{"let s = 'open\nlet t = 1;", "unterminated string literal", 9},
+		{"let s = `open", "unterminated raw string literal", 9},
+		{`"bad \q escape"`, `unknown escape sequence \q`, 6},
+		{`"\x80"`, `invalid \x escape: expected two hex digits no greater than 7F`, 2},
+		{`"\x4"`, `invalid \x escape: expected two hex digits no greater than 7F`, 2},
+		{`"\u41"`, `invalid \u escape: expected \u{...}`, 2},
+		{`"\u{}"`, `invalid \u escape: expected one to six hex digits followed by }`, 2},
+		{`"\u{D800}"`, `invalid \u escape: U+D800 is not a valid code point`, 2},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+
+		if len(errors) != 1 {
+			t.Errorf("tests[%d] - wrong number of errors. expected=1, got=%d (%v)", i, len(errors), errors)
+			continue
+		}
+
+		if errors[0].Msg != tt.expectedMsg {
+			t.Errorf("tests[%d] - wrong message. expected=%q, got=%q", i, tt.expectedMsg, errors[0].Msg)
+		}
+
+		if pos := l.File().Position(errors[0].Pos); pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong column. expected=%d, got=%d", i, tt.expectedColumn, pos.Column)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := "let café = \"héllo\"; let 名前 = café; नमस्ते € x"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "café", 5},
+		{token.ASSIGN, "=", 11},
+		{token.STRING, "héllo", 13},
+		{token.SEMI, ";", 21},
+		{token.LET, "let", 23},
+		{token.IDENT, "名前", 27},
+		{token.ASSIGN, "=", 34},
+		{token.IDENT, "café", 36},
+		{token.SEMI, ";", 41},
+		{token.IDENT, "नमस्ते", 43},
+		{token.ILLEGAL, "€", 62},
+		{token.IDENT, "x", 66},
+		{token.EOF, "", 67},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if pos := l.File().Position(tok.Pos); pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong column. expected=%d, got=%d", i, tt.expectedColumn, pos.Column)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("Unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "5 3.14 1e-9 2.5E+3 10e x 0.5 0xFF 0b102 0o7_5 1_000 0x 1r 0.5r 3rd"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.INT, "10"},
+		{token.IDENT, "e"},
+		{token.IDENT, "x"},
+		{token.FLOAT, "0.5"},
+		{token.INT, "0xFF"},
+		{token.INT, "0b102"},
+		{token.INT, "0o7_5"},
+		{token.INT, "1_000"},
+		{token.INT, "0x"},
+		{token.RAT, "1r"},
+		{token.RAT, "0.5r"},
+		{token.INT, "3"},
+		{token.IDENT, "rd"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("let x = \xff;")
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	if len(l.Errors()) != 1 || l.Errors()[0].Msg != "invalid UTF-8 encoding" {
+		t.Fatalf("Expected a single invalid UTF-8 error. got=%v", l.Errors())
+	}
+
+	if pos := l.File().Position(l.Errors()[0].Pos); pos.Column != 9 {
+		t.Errorf("Wrong error column. expected=9, got=%d", pos.Column)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := "<= >= < > && || % ** * & | ^ ~ << >> a!=~b<<1<2>>3>=4&5|6^7<-8 += -= *= /= %= x+=-1"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.BIT_AND, "&"},
+		{token.BIT_OR, "|"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.SHL, "<<"},
+		{token.SHR, ">>"},
+		{token.IDENT, "a"},
+		{token.NOT_EQ, "!="},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "b"},
+		{token.SHL, "<<"},
+		{token.INT, "1"},
+		{token.LT, "<"},
+		{token.INT, "2"},
+		{token.SHR, ">>"},
+		{token.INT, "3"},
+		{token.GT_EQ, ">="},
+		{token.INT, "4"},
+		{token.BIT_AND, "&"},
+		{token.INT, "5"},
+		{token.BIT_OR, "|"},
+		{token.INT, "6"},
+		{token.BIT_XOR, "^"},
+		{token.INT, "7"},
+		{token.LT, "<"},
+		{token.MINUS, "-"},
+		{token.INT, "8"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := "while (x) { break; continue; } whiled for (k, v in h) {}"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMI, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMI, ";"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "whiled"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "k"},
+		{token.COMMA, ","},
+		{token.IDENT, "v"},
+		{token.IN, "in"},
+		{token.IDENT, "h"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestPipeTokens(t *testing.T) {
+	input := "a |> f() || b | c|>d"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.OR, "||"},
+		{token.IDENT, "b"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "c"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "d"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNullHandlingTokens(t *testing.T) {
+	input := "a ? b : c ?? d h?.[0] f?.(x) ???."
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
+		{token.NULL_COALESCE, "??"},
+		{token.IDENT, "d"},
+		{token.IDENT, "h"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.IDENT, "f"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.NULL_COALESCE, "??"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestDotTokens(t *testing.T) {
+	input := "a.b.c(1.5) 5.len() x?.y ..."
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.DOT, "."},
+		{token.IDENT, "c"},
+		{token.LPAREN, "("},
+		{token.FLOAT, "1.5"},
+		{token.RPAREN, ")"},
+		{token.INT, "5"},
+		{token.DOT, "."},
+		{token.IDENT, "len"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.IDENT, "x"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.IDENT, "y"},
+		{token.ELLIPSIS, "..."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := "match (x) { [a, ...rest] => a, _ => 0 } == >= ..x"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RBRACKET, "]"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.IDENT, "_"},
+		{token.ARROW, "=>"},
+		{token.INT, "0"},
+		{token.RBRACE, "}"},
+		{token.EQ, "=="},
+		{token.GT_EQ, ">="},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
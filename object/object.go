@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Sheep42/Monkey-Lang/ast"
@@ -12,14 +16,19 @@ import (
 const (
 	StringObj      = "STRING"
 	IntegerObj     = "INTEGER"
+	FloatObj       = "FLOAT"
+	RationalObj    = "RATIONAL"
 	NullObj        = "NULL"
 	BooleanObj     = "BOOLEAN"
 	ReturnValueObj = "RETURN_VALUE"
+	BreakObj       = "BREAK"
+	ContinueObj    = "CONTINUE"
 	ErrorObj       = "ERROR"
 	FunctionObj    = "FUNCTION"
 	BuiltinObj     = "BUILTIN"
 	ArrayObj       = "ARRAY"
 	HashObj        = "HASH"
+	RangeObj       = "RANGE"
 )
 
 type BuiltinFn func(args ...Object) Object
@@ -65,6 +74,23 @@ func (e *Environment) Set(name string, val Object) Object {
 
 }
 
+// Assign rebinds name in the nearest environment that already defines it. It
+// returns false, leaving every store untouched, if name is not defined.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+
+	for env := e; env != nil; env = env.outer {
+
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+
+	}
+
+	return nil, false
+
+}
+
 type ObjectType string
 
 type Object interface {
@@ -88,6 +114,44 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return IntegerObj }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+// BigInteger holds integers that don't fit in an int64. It reports the same
+// type as Integer so the two are interchangeable in Monkey code.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (b *BigInteger) Type() ObjectType { return IntegerObj }
+func (b *BigInteger) Inspect() string  { return b.Value.String() }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FloatObj }
+
+// Inspect always shows a decimal point or exponent so floats stay
+// distinguishable from integers, e.g. 2.0 rather than 2
+func (f *Float) Inspect() string {
+
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+
+	return s
+
+}
+
+// Rational is an exact fraction. Inspect always shows it in lowest terms as
+// numerator/denominator, e.g. 1/3 or 2/1.
+type Rational struct {
+	Value *big.Rat
+}
+
+func (r *Rational) Type() ObjectType { return RationalObj }
+func (r *Rational) Inspect() string  { return r.Value.String() }
+
 type String struct {
 	Value string
 }
@@ -109,15 +173,32 @@ type ReturnValue struct {
 func (r *ReturnValue) Type() ObjectType { return ReturnValueObj }
 func (r *ReturnValue) Inspect() string  { return r.Value.Inspect() }
 
+// Break and Continue signal a loop from inside its body. Like ReturnValue they
+// stop evaluation of the enclosing blocks, but the nearest loop consumes them.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BreakObj }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return ContinueObj }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+
+	// Internal marks errors caused by a bug in the interpreter rather than in
+	// the evaluated program
+	Internal bool
 }
 
 func (e *Error) Type() ObjectType { return ErrorObj }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
-	Parameters []*ast.Identifier
+	Name       string // empty for anonymous functions
+	Parameters []ast.Pattern
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -172,6 +253,12 @@ type HashKey struct {
 	Value uint64
 }
 
+// bigIntegerKey tags BigInteger hash keys. A BigInteger reports INTEGER as its
+// type, but its hashed value could equal some Integer's raw value, so its keys
+// need a space of their own. No Integer is ever equal to a BigInteger, so
+// nothing that should match is kept apart.
+const bigIntegerKey ObjectType = "BIG_INTEGER"
+
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 
@@ -188,6 +275,52 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (b *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+
+	if b.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+
+	h.Write(b.Value.Bytes())
+
+	return HashKey{Type: bigIntegerKey, Value: h.Sum64()}
+}
+
+func (r *Rational) HashKey() HashKey {
+
+	// a whole rational equals the integer with the same value, so it must also
+	// find the same hash entries
+	if r.Value.IsInt() {
+
+		if num := r.Value.Num(); num.IsInt64() {
+			return (&Integer{Value: num.Int64()}).HashKey()
+		}
+
+		return (&BigInteger{Value: new(big.Int).Set(r.Value.Num())}).HashKey()
+
+	}
+
+	h := fnv.New64a()
+
+	// big.Rat is always normalised, so equal values have equal strings
+	h.Write([]byte(r.Value.String()))
+
+	return HashKey{Type: r.Type(), Value: h.Sum64()}
+}
+
+func (f *Float) HashKey() HashKey {
+
+	value := f.Value
+
+	// -0.0 and 0.0 compare equal, so they must share a key
+	if value == 0 {
+		value = 0
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -210,7 +343,7 @@ func (h *Hash) Inspect() string {
 
 	pairs := []string{}
 
-	for _, pair := range h.Pairs {
+	for _, pair := range h.SortedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -221,8 +354,111 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// SortedPairs returns the pairs ordered by key, first by the key's type name
+// and then by value. It gives iteration and Inspect a stable order.
+func (h *Hash) SortedPairs() []HashPair {
+
+	pairs := make([]HashPair, 0, len(h.Pairs))
+
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return keyLess(pairs[i].Key, pairs[j].Key) })
+
+	return pairs
+
+}
+
+func keyLess(a, b Object) bool {
+
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+
+	case *String:
+		return a.Value < b.(*String).Value
+
+	case *Float:
+		bVal := b.(*Float).Value
+		return a.Value < bVal || (math.IsNaN(bVal) && !math.IsNaN(a.Value))
+
+	case *Rational:
+		return a.Value.Cmp(b.(*Rational).Value) < 0
+
+	case *Integer, *BigInteger:
+		return bigIntOf(a).Cmp(bigIntOf(b)) < 0
+
+	}
+
+	return false
+
+}
+
+// bigIntOf returns the value of an Integer or BigInteger as a *big.Int
+func bigIntOf(obj Object) *big.Int {
+
+	if i, ok := obj.(*Integer); ok {
+		return big.NewInt(i.Value)
+	}
+
+	return obj.(*BigInteger).Value
+
+}
+
+// Range is the lazy sequence of integers produced by the range builtin. Its
+// elements are computed as they are iterated.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64 // never 0
+}
+
+func (r *Range) Type() ObjectType { return RangeObj }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Len returns the number of elements in the range. A range can span more than
+// math.MaxInt64 elements, in which case the result is negative; the range
+// builtin refuses to create such ranges.
+func (r *Range) Len() int64 {
+
+	// uint64 keeps the distance exact even when it overflows an int64
+	if r.Step > 0 && r.Start < r.Stop {
+		return int64(uint64(r.Stop-r.Start-1)/uint64(r.Step) + 1)
+	}
+
+	if r.Step < 0 && r.Start > r.Stop {
+		return int64(uint64(r.Start-r.Stop-1)/uint64(-r.Step) + 1)
+	}
+
+	return 0
+
+}
+
+// At returns the i'th element of the range. i must be less than Len().
+func (r *Range) At(i int64) int64 {
+	return r.Start + i*r.Step
+}
+
 type Builtin struct {
 	Fn BuiltinFn
+
+	// Params names the parameters of builtins that accept keyword arguments,
+	// nil for the others
+	Params []string
+
+	// Defaults holds a value for each of Params, nil where the argument is
+	// required. A builtin with Defaults is passed every parameter when it is
+	// called with keyword arguments, so it never has to guess which parameter
+	// a shorter argument list leaves out.
+	Defaults []Object
 }
 
 func (b *Builtin) Type() ObjectType { return BuiltinObj }
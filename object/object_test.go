@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -21,3 +25,119 @@ func TestStringHashKey(t *testing.T) {
 	}
 
 }
+
+func TestFloatHashKey(t *testing.T) {
+	a := &Float{Value: 2.5}
+	b := &Float{Value: 2.5}
+	zero := &Float{Value: 0}
+	negZero := &Float{Value: math.Copysign(0, -1)}
+
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("Floats with same value have different hash keys")
+	}
+
+	if zero.HashKey() != negZero.HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+
+	if a.HashKey() == (&Integer{Value: 2}).HashKey() {
+		t.Errorf("Float and Integer share a hash key")
+	}
+
+}
+
+func TestBigIntegerHashKey(t *testing.T) {
+	a, _ := new(big.Int).SetString("18446744073709551616", 10)
+	b, _ := new(big.Int).SetString("18446744073709551616", 10)
+	neg := new(big.Int).Neg(a)
+
+	if (&BigInteger{Value: a}).HashKey() != (&BigInteger{Value: b}).HashKey() {
+		t.Errorf("BigIntegers with same value have different hash keys")
+	}
+
+	if (&BigInteger{Value: a}).HashKey() == (&BigInteger{Value: neg}).HashKey() {
+		t.Errorf("BigIntegers with opposite signs have same hash keys")
+	}
+
+	// an Integer whose raw value is the BigInteger's hash
+	collision := &Integer{Value: int64((&BigInteger{Value: a}).HashKey().Value)}
+
+	if (&BigInteger{Value: a}).HashKey() == collision.HashKey() {
+		t.Errorf("BigInteger shares a hash key with %d", collision.Value)
+	}
+
+}
+
+func TestRationalHashKey(t *testing.T) {
+	half := &Rational{Value: big.NewRat(1, 2)}
+	twoQuarters := &Rational{Value: big.NewRat(2, 4)}
+	third := &Rational{Value: big.NewRat(1, 3)}
+
+	if half.HashKey() != twoQuarters.HashKey() {
+		t.Errorf("Equal rationals have different hash keys")
+	}
+
+	if half.HashKey() == third.HashKey() {
+		t.Errorf("Different rationals have same hash keys")
+	}
+
+	if (&Rational{Value: big.NewRat(4, 2)}).HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("Whole rational and equal integer have different hash keys")
+	}
+
+	n, _ := new(big.Int).SetString("18446744073709551616", 10)
+
+	if (&Rational{Value: new(big.Rat).SetInt(n)}).HashKey() != (&BigInteger{Value: n}).HashKey() {
+		t.Errorf("Whole rational and equal big integer have different hash keys")
+	}
+
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if _, ok := inner.Assign("x", &Integer{Value: 2}); !ok {
+		t.Fatalf("Assign failed for a name defined in the outer environment")
+	}
+
+	if val, _ := outer.Get("x"); val.(*Integer).Value != 2 {
+		t.Errorf("Assign did not update the outer binding. got=%s", val.Inspect())
+	}
+
+	if _, ok := inner.store["x"]; ok {
+		t.Errorf("Assign created a binding in the inner environment")
+	}
+
+	if _, ok := inner.Assign("y", &Integer{Value: 3}); ok {
+		t.Errorf("Assign succeeded for an undefined name")
+	}
+
+	if _, ok := inner.Get("y"); ok {
+		t.Errorf("failed Assign created a binding")
+	}
+
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        *Range
+		expected int64
+	}{
+		{&Range{Start: 0, Stop: 5, Step: 1}, 5},
+		{&Range{Start: 0, Stop: 10, Step: 3}, 4},
+		{&Range{Start: 5, Stop: 0, Step: -2}, 3},
+		{&Range{Start: 5, Stop: 5, Step: 1}, 0},
+		{&Range{Start: 5, Stop: 0, Step: 1}, 0},
+		{&Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: math.MaxInt64}, 3},
+		{&Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: 1}, -1},
+	}
+
+	for i, tt := range tests {
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("tests[%d] - wrong Len for %s. expected=%d, got=%d", i, tt.r.Inspect(), tt.expected, got)
+		}
+	}
+
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Sheep42/Monkey-Lang/token"
+)
+
+// ParseError describes a single syntax error found by the Parser
+type ParseError struct {
+	Pos      token.Position    // where the error was detected
+	Expected []token.TokenType // token types that would have been accepted, if known
+	Got      token.Token       // the offending token
+	Msg      string            // human readable description
+	Hint     string            // suggested fix for common mistakes, may be empty
+}
+
+func (e *ParseError) Error() string {
+
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Msg
+	}
+
+	return e.Msg
+
+}
+
+// Render formats the error together with the offending source line and a
+// caret under the bad column. src must be the text the error was found in.
+func (e *ParseError) Render(src string) string {
+
+	var out bytes.Buffer
+
+	out.WriteString(e.Error())
+	out.WriteString("\n")
+
+	if e.Pos.IsValid() && e.Pos.Offset <= len(src) {
+
+		start := e.Pos.Offset - (e.Pos.Column - 1)
+
+		if start < 0 {
+			start = 0
+		}
+
+		end := strings.IndexByte(src[start:], '\n')
+
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start
+		}
+
+		line := strings.TrimRight(src[start:end], "\r")
+
+		out.WriteString("    ")
+		out.WriteString(line)
+		out.WriteString("\n    ")
+		out.WriteString(caretPadding(src[start:e.Pos.Offset]))
+		out.WriteString("^\n")
+
+	}
+
+	if e.Hint != "" {
+		out.WriteString("    hint: ")
+		out.WriteString(e.Hint)
+		out.WriteString("\n")
+	}
+
+	return out.String()
+
+}
+
+// caretPadding returns whitespace that lines a caret up with the end of
+// prefix, keeping tabs so the caret lines up however the terminal renders them
+func caretPadding(prefix string) string {
+
+	var out bytes.Buffer
+
+	for len(prefix) > 0 {
+
+		r, size := utf8.DecodeRuneInString(prefix)
+		prefix = prefix[size:]
+
+		if r == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+
+	}
+
+	return out.String()
+
+}
+
+// closingHints maps closing delimiters to the hint shown when one is missing
+var closingHints = map[token.TokenType]string{
+	token.RPAREN:   `missing closing ")"`,
+	token.RBRACKET: `missing closing "]"`,
+	token.RBRACE:   `missing closing "}"`,
+}
+
+// hintFor suggests a fix for common mistakes, given what was expected and
+// what was found instead. unmatched reports whether got is a closing delimiter
+// that nothing before it opened.
+func hintFor(expected []token.TokenType, got token.Token, unmatched bool) string {
+
+	if got.Type == token.ILLEGAL {
+		return fmt.Sprintf("%q is not valid here; remove it or put it inside a string", got.Literal)
+	}
+
+	for _, t := range expected {
+
+		if hint, ok := closingHints[t]; ok {
+			return hint
+		}
+
+	}
+
+	switch got.Type {
+
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		if unmatched {
+			return fmt.Sprintf("unmatched %q", got.Literal)
+		}
+
+	case token.EOF:
+		return "the input ended before the expression was complete"
+
+	}
+
+	return ""
+
+}
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/Sheep42/Monkey-Lang/ast"
 	"github.com/Sheep42/Monkey-Lang/lexer"
@@ -12,25 +14,56 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN   // = += -= *= /= %=
+	TERNARY  // ? :
+	COALESCE // ??
+	OR       // ||
+	AND      // &&
+	PIPE     // |>
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
+	POWER // binds tighter than prefix operators, so -2 ** 2 is -(2 ** 2)
 	CALL
 	INDEX // Array indeces
 )
 
 // precedence table
 var precedences = map[token.TokenType]int{
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+
+	token.QUESTION:       TERNARY,
+	token.NULL_COALESCE:  COALESCE,
+	token.OPTIONAL_CHAIN: INDEX,
+	token.DOT:            INDEX,
+
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
+	token.OR:       OR,
+	token.AND:      AND,
+	token.PIPE:     PIPE,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LT_EQ:    LESSGREATER,
+	token.GT_EQ:    LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
+	token.BIT_OR:   SUM, // bitwise operators sit at the same levels as in Go
+	token.BIT_XOR:  SUM,
 	token.ASTERISK: PRODUCT,
 	token.SLASH:    PRODUCT,
+	token.PERCENT:  PRODUCT,
+	token.BIT_AND:  PRODUCT,
+	token.SHL:      PRODUCT,
+	token.SHR:      PRODUCT,
+	token.POWER:    POWER,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
 }
@@ -45,7 +78,29 @@ type Parser struct {
 
 	curToken  token.Token
 	peekToken token.Token
-	errors    []string
+	errors    []*ParseError
+
+	// number of parse failures reported through addError, which unlike
+	// len(errors) leaves out lexer errors and counts duplicates. Only a parse
+	// failure starts statement recovery.
+	parseErrors int
+
+	// number of parse failures a statement loop has already recovered from
+	handledErrors int
+
+	// number of lexer errors already turned into ParseErrors
+	lexerErrors int
+
+	// delimiters opened up to the current token and not closed yet, keyed by
+	// the token type that closes them, and the position of the last closing
+	// delimiter that had nothing to close. Used to tell whether a closing
+	// delimiter is really unmatched.
+	openDelims  map[token.TokenType]int
+	strayCloser token.Pos
+
+	// number of loops enclosing the current token, reset inside function
+	// literals so break and continue can't reach an outer function's loop
+	loopDepth int
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -53,8 +108,9 @@ type Parser struct {
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:          l,
+		errors:     []*ParseError{},
+		openDelims: make(map[token.TokenType]int),
 	}
 
 	//Read 2 tokens - sets curToken/peekToken
@@ -65,13 +121,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.RAT, p.parseRationalLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
@@ -82,10 +142,32 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.NULL_COALESCE, p.parseInfixExpression)
+	p.registerInfix(token.QUESTION, p.parseConditionalExpression)
+	p.registerInfix(token.OPTIONAL_CHAIN, p.parseOptionalChain)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
@@ -102,14 +184,82 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	literal := &ast.IntegerLiteral{Token: p.curToken}
 
-	val, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	digits, base, err := splitIntegerLiteral(p.curToken.Literal)
 
 	if err != nil {
 
-		msg := fmt.Sprintf("Could not parse %q as integer.", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, nil, err.Error())
+		return p.badExpression(p.curToken.Pos)
 
-		return nil
+	}
+
+	val, err := strconv.ParseInt(digits, base, 64)
+
+	// digits are already validated, so this can only be an int64 overflow
+	if err != nil {
+
+		literal.Big, _ = new(big.Int).SetString(digits, base)
+		return literal
+
+	}
+
+	literal.Value = val
+
+	return literal
+
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+
+	literal := &ast.FloatLiteral{Token: p.curToken}
+
+	digits, err := removeFloatSeparators(p.curToken.Literal)
+
+	if err != nil {
+
+		p.addError(p.curToken, nil, err.Error())
+		return p.badExpression(p.curToken.Pos)
+
+	}
+
+	val, err := strconv.ParseFloat(digits, 64)
+
+	if err != nil {
+
+		msg := fmt.Sprintf("Could not parse %q as float.", p.curToken.Literal)
+		p.addError(p.curToken, nil, msg)
+
+		return p.badExpression(p.curToken.Pos)
+
+	}
+
+	literal.Value = val
+
+	return literal
+
+}
+
+func (p *Parser) parseRationalLiteral() ast.Expression {
+
+	literal := &ast.RationalLiteral{Token: p.curToken}
+
+	digits, err := removeFloatSeparators(strings.TrimSuffix(p.curToken.Literal, "r"))
+
+	if err != nil {
+
+		p.addError(p.curToken, nil, err.Error())
+		return p.badExpression(p.curToken.Pos)
+
+	}
+
+	val, ok := new(big.Rat).SetString(digits)
+
+	if !ok {
+
+		msg := fmt.Sprintf("Could not parse %q as rational.", p.curToken.Literal)
+		p.addError(p.curToken, nil, msg)
+
+		return p.badExpression(p.curToken.Pos)
 
 	}
 
@@ -119,6 +269,98 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 }
 
+// splitIntegerLiteral checks the spelling of an integer literal. It returns the
+// digits with any base prefix and '_' separators removed, along with the base.
+func splitIntegerLiteral(lit string) (string, int, error) {
+
+	base, name, digits := 10, "decimal", lit
+
+	if len(lit) > 1 && lit[0] == '0' {
+
+		switch lit[1] {
+		case 'x', 'X':
+			base, name, digits = 16, "hexadecimal", lit[2:]
+		case 'o', 'O':
+			base, name, digits = 8, "octal", lit[2:]
+		case 'b', 'B':
+			base, name, digits = 2, "binary", lit[2:]
+		}
+
+	}
+
+	if digits == "" {
+		return "", 0, fmt.Errorf("%s literal %q has no digits", name, lit)
+	}
+
+	var out strings.Builder
+
+	for i := 0; i < len(digits); i++ {
+
+		ch := digits[i]
+
+		if ch == '_' {
+
+			if i == 0 || i == len(digits)-1 || digits[i+1] == '_' {
+				return "", 0, fmt.Errorf("'_' must separate successive digits in %q", lit)
+			}
+
+			continue
+
+		}
+
+		if digitValue(ch) >= base {
+			return "", 0, fmt.Errorf("invalid digit %q in %s literal %q", ch, name, lit)
+		}
+
+		out.WriteByte(ch)
+
+	}
+
+	if base == 10 && out.Len() > 1 && lit[0] == '0' {
+		return "", 0, fmt.Errorf("leading zeros are not allowed in decimal literal %q; use the 0o prefix for octal", lit)
+	}
+
+	return out.String(), base, nil
+
+}
+
+// removeFloatSeparators strips '_' separators from a float literal, checking
+// that each one sits between two decimal digits
+func removeFloatSeparators(lit string) (string, error) {
+
+	for i := 0; i < len(lit); i++ {
+
+		if lit[i] == '_' && (i == 0 || i == len(lit)-1 || !isDecimal(lit[i-1]) || !isDecimal(lit[i+1])) {
+			return "", fmt.Errorf("'_' must separate successive digits in %q", lit)
+		}
+
+	}
+
+	return strings.ReplaceAll(lit, "_", ""), nil
+
+}
+
+// digitValue returns the numeric value of a digit in any base up to 36, or 36
+// if ch is not a digit at all
+func digitValue(ch byte) int {
+
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'z':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'Z':
+		return int(ch-'A') + 10
+	}
+
+	return 36
+
+}
+
+func isDecimal(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -129,6 +371,12 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
 
+	if array.Elements == nil {
+		return p.badExpression(array.Token.Pos)
+	}
+
+	array.Rbrack = p.curToken.Pos
+
 	return array
 
 }
@@ -137,13 +385,15 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	errs := p.parseErrors
+	exp.Index = p.parseOperand(LOWEST)
 
-	if !p.expectPeek(token.RBRACKET) {
-		return nil
+	if !p.expectPeekAfter(token.RBRACKET, errs) {
+		return p.badExpression(left.Pos())
 	}
 
+	exp.Rbrack = p.curToken.Pos
+
 	return exp
 
 }
@@ -155,28 +405,29 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 	for !p.peekTokenIs(token.RBRACE) {
 
-		p.nextToken()
-		key := p.parseExpression(LOWEST)
+		errs := p.parseErrors
+		key := p.parseOperand(LOWEST)
 
-		if !p.expectPeek(token.COLON) {
-			return nil
+		if !p.expectPeekAfter(token.COLON, errs) {
+			return p.badExpression(hash.Token.Pos)
 		}
 
-		p.nextToken()
-		value := p.parseExpression(LOWEST)
+		value := p.parseOperand(LOWEST)
 
 		hash.Pairs[key] = value
 
-		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeekAfter(token.COMMA, errs) {
+			return p.badExpression(hash.Token.Pos)
 		}
 
 	}
 
 	if !p.expectPeek(token.RBRACE) {
-		return nil
+		return p.badExpression(hash.Token.Pos)
 	}
 
+	hash.Rbrace = p.curToken.Pos
+
 	return hash
 
 }
@@ -188,9 +439,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 		Operator: p.curToken.Literal,
 	}
 
-	p.nextToken()
-
-	expr.Right = p.parseExpression(PREFIX)
+	expr.Right = p.parseOperand(PREFIX)
 
 	return expr
 
@@ -209,13 +458,14 @@ func (p *Parser) parseBoolean() ast.Expression {
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
 
-	p.nextToken()
+	start := p.curToken.Pos
+	errs := p.parseErrors
 
-	exp := p.parseExpression(LOWEST)
+	exp := p.parseOperand(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeekAfter(token.RPAREN, errs) {
 
-		return nil
+		return p.badExpression(start)
 
 	}
 
@@ -228,18 +478,18 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	exp := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(exp.Token.Pos)
 	}
 
-	p.nextToken()
-	exp.Condition = p.parseExpression(LOWEST)
+	errs := p.parseErrors
+	exp.Condition = p.parseOperand(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if !p.expectPeekAfter(token.RPAREN, errs) {
+		return p.badExpression(exp.Token.Pos)
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(exp.Token.Pos)
 	}
 
 	exp.Consequence = p.parseBlockStatement()
@@ -248,9 +498,25 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+
+			p.nextToken()
+
+			elseIf, ok := p.parseIfExpression().(*ast.IfExpression)
+
+			if !ok {
+				return p.badExpression(exp.Token.Pos)
+			}
+
+			exp.ElseIf = elseIf
+
+			return exp
+
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 
-			return nil
+			return p.badExpression(exp.Token.Pos)
 
 		}
 
@@ -262,187 +528,799 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 }
 
-func (p *Parser) parseFunctionLiteral() ast.Expression {
+func (p *Parser) parseMatchExpression() ast.Expression {
 
-	fn := &ast.FunctionLiteral{Token: p.curToken}
+	exp := &ast.MatchExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-
-		return nil
-
+		return p.badExpression(exp.Token.Pos)
 	}
 
-	fn.Parameters = p.parseFunctionParams()
+	errs := p.parseErrors
+	exp.Subject = p.parseOperand(LOWEST)
 
-	if !p.expectPeek(token.LBRACE) {
+	if !p.expectPeekAfter(token.RPAREN, errs) || !p.expectPeek(token.LBRACE) {
+		return p.badExpression(exp.Token.Pos)
+	}
 
-		return nil
+	for !p.peekTokenIs(token.RBRACE) {
 
-	}
+		p.nextToken()
 
-	fn.Body = p.parseBlockStatement()
+		arm := &ast.MatchArm{Pattern: p.parsePattern()}
 
-	return fn
+		if arm.Pattern == nil {
+			return p.badExpression(exp.Token.Pos)
+		}
 
-}
+		if p.peekTokenIs(token.IF) {
 
-func (p *Parser) parseFunctionParams() []*ast.Identifier {
+			p.nextToken()
+			arm.Guard = p.parseOperand(LOWEST)
 
-	idents := []*ast.Identifier{}
+		}
 
-	// test for the end of params
-	if p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.ARROW) {
+			return p.badExpression(exp.Token.Pos)
+		}
 
-		p.nextToken()
-		return idents
+		// a brace after the arrow always starts a block, wrap a hash literal
+		// in parentheses to return it from an arm
+		if p.peekTokenIs(token.LBRACE) {
 
-	}
+			p.nextToken()
+			arm.Body = p.parseBlockStatement()
 
-	p.nextToken()
+		} else {
+			arm.Body = p.parseOperand(LOWEST)
+		}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	idents = append(idents, ident)
+		exp.Arms = append(exp.Arms, arm)
 
-	for p.peekTokenIs(token.COMMA) {
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			continue
+		}
 
-		p.nextToken()
-		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		idents = append(idents, ident)
+		// arms with a block body don't need a separating comma
+		_, isBlock := arm.Body.(*ast.BlockStatement)
 
-	}
+		if !isBlock && !p.peekTokenIs(token.RBRACE) {
 
-	if !p.expectPeek(token.RPAREN) {
+			p.peekError(token.COMMA)
+			return p.badExpression(exp.Token.Pos)
 
-		return nil
+		}
 
 	}
 
-	return idents
+	p.nextToken()
+	exp.Rbrace = p.curToken.Pos
+
+	return exp
 
 }
 
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+// parsePattern parses the match pattern starting at the current token. It
+// returns nil after recording an error if there is no valid pattern there.
+func (p *Parser) parsePattern() ast.Pattern {
 
-	block := &ast.BlockStatement{Token: p.curToken}
+	switch p.curToken.Type {
 
-	block.Statements = []ast.Statement{}
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	p.nextToken()
+	case token.INT, token.FLOAT, token.RAT, token.STRING, token.TRUE, token.FALSE:
+		// a malformed literal must come back as an untyped nil, not a nil
+		// *ast.LiteralPattern that callers would take for a pattern
+		if lit := p.parseLiteralPattern(); lit != nil {
+			return lit
+		}
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		return nil
 
-		stmt := p.parseStatement()
+	case token.MINUS:
+		switch p.peekToken.Type {
 
-		if stmt != nil {
+		case token.INT, token.FLOAT, token.RAT:
+			minus := p.curToken
+			p.nextToken()
 
-			block.Statements = append(block.Statements, stmt)
+			lit := p.parseLiteralPattern()
 
-		}
+			if lit == nil {
+				return nil
+			}
 
-		p.nextToken()
+			return &ast.LiteralPattern{Value: &ast.PrefixExpression{Token: minus, Operator: "-", Right: lit.Value}}
 
-		if p.curTokenIs(token.SEMI) {
+		}
 
-			p.nextToken()
+	case token.LBRACKET:
+		return p.parseArrayPattern()
 
-		}
+	case token.LBRACE:
+		return p.parseHashPattern()
 
 	}
 
-	return block
+	msg := fmt.Sprintf("expected a pattern, got %s", p.curToken.Type)
+	p.addError(p.curToken, nil, msg)
 
-}
+	return nil
 
-func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+}
 
-	expr := &ast.InfixExpression{
-		Token:    p.curToken,
-		Left:     left,
-		Operator: p.curToken.Literal,
-	}
+// parseLiteralPattern parses a single literal token, without going through
+// parseExpression so no operators are picked up after it
+func (p *Parser) parseLiteralPattern() *ast.LiteralPattern {
 
-	pr := p.curPrecedence()
-	p.nextToken()
+	value := p.prefixParseFns[p.curToken.Type]()
 
-	expr.Right = p.parseExpression(pr)
+	if _, ok := value.(*ast.BadExpression); ok {
+		return nil
+	}
 
-	return expr
+	return &ast.LiteralPattern{Value: value}
 
 }
 
-func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
+func (p *Parser) parseArrayPattern() ast.Pattern {
 
-	exp := &ast.CallExpression{Token: p.curToken, Function: fn}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	pattern := &ast.ArrayPattern{Token: p.curToken}
 
-	return exp
+	for !p.peekTokenIs(token.RBRACKET) {
 
-}
+		p.nextToken()
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+		if p.curTokenIs(token.ELLIPSIS) {
 
-	list := []ast.Expression{}
+			pattern.Rest = &ast.RestPattern{Token: p.curToken}
 
-	if p.peekTokenIs(end) {
+			if p.peekTokenIs(token.IDENT) {
+				p.nextToken()
+				pattern.Rest.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			}
 
-		p.nextToken()
-		return list
+			// the rest pattern has to come last
+			break
 
-	}
+		}
 
-	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+		el := p.parsePattern()
 
-	for p.peekTokenIs(token.COMMA) {
+		if el == nil {
+			return nil
+		}
 
-		p.nextToken()
-		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		pattern.Elements = append(pattern.Elements, p.parseDefault(el))
 
-	}
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
 
-	if !p.expectPeek(end) {
+	}
 
+	if !p.expectPeek(token.RBRACKET) {
 		return nil
-
 	}
 
-	return list
+	pattern.Rbrack = p.curToken.Pos
 
-}
+	return pattern
 
-func (p *Parser) nextToken() {
-	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
 }
 
-func (p *Parser) curTokenIs(t token.TokenType) bool {
-	return p.curToken.Type == t
-}
+func (p *Parser) parseHashPattern() ast.Pattern {
 
-func (p *Parser) peekPrecedence() int {
+	pattern := &ast.HashPattern{Token: p.curToken}
 
-	if pr, ok := precedences[p.peekToken.Type]; ok {
-		return pr
-	}
+	for !p.peekTokenIs(token.RBRACE) {
 
-	return LOWEST
+		p.nextToken()
 
-}
+		pair := p.parseHashPatternPair()
 
-func (p *Parser) curPrecedence() int {
+		if pair == nil {
+			return nil
+		}
+
+		pattern.Pairs = append(pattern.Pairs, pair)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
 
-	if pr, ok := precedences[p.curToken.Type]; ok {
-		return pr
 	}
 
-	return LOWEST
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
 
-}
+	pattern.Rbrace = p.curToken.Pos
+
+	return pattern
 
-func (p *Parser) peekTokenIs(t token.TokenType) bool {
-	return p.peekToken.Type == t
+}
+
+func (p *Parser) parseHashPatternPair() *ast.HashPatternPair {
+
+	pair := &ast.HashPatternPair{}
+
+	if p.curTokenIs(token.IDENT) {
+
+		name := p.curToken
+		pair.Key = &ast.StringLiteral{Token: name, Value: name.Literal}
+
+		if !p.peekTokenIs(token.COLON) {
+
+			pair.Value = p.parseDefault(&ast.Identifier{Token: name, Value: name.Literal})
+			pair.Shorthand = true
+
+			return pair
+
+		}
+
+	} else {
+
+		tok := p.curToken
+		key := p.parsePattern()
+
+		if key == nil {
+			return nil
+		}
+
+		lit, ok := key.(*ast.LiteralPattern)
+
+		if !ok {
+
+			msg := fmt.Sprintf("hash pattern keys must be literals, got %s", key.String())
+			p.addError(tok, nil, msg)
+
+			return nil
+
+		}
+
+		pair.Key = lit.Value
+
+	}
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+
+	pair.Value = p.parsePattern()
+
+	if pair.Value == nil {
+		return nil
+	}
+
+	pair.Value = p.parseDefault(pair.Value)
+
+	return pair
+
+}
+
+// parseDefault wraps pattern in a DefaultPattern if it is followed by
+// = default
+func (p *Parser) parseDefault(pattern ast.Pattern) ast.Pattern {
+
+	if !p.peekTokenIs(token.ASSIGN) {
+		return pattern
+	}
+
+	p.nextToken()
+
+	return &ast.DefaultPattern{Pattern: pattern, Default: p.parseOperand(LOWEST)}
+
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+
+	fn := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+
+		return p.badExpression(fn.Token.Pos)
+
+	}
+
+	fn.Parameters = p.parseFunctionParams()
+
+	if fn.Parameters == nil || !p.expectPeek(token.LBRACE) {
+
+		return p.badExpression(fn.Token.Pos)
+
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+
+	fn.Body = p.parseBlockStatement()
+
+	p.loopDepth = loopDepth
+
+	return fn
+
+}
+
+func (p *Parser) parseFunctionParams() []ast.Pattern {
+
+	params := []ast.Pattern{}
+
+	// test for the end of params
+	if p.peekTokenIs(token.RPAREN) {
+
+		p.nextToken()
+		return params
+
+	}
+
+	hasDefault := false
+
+	for {
+
+		tok := p.peekToken
+		param := p.parseParam()
+
+		if param == nil {
+
+			return nil
+
+		}
+
+		// defaults are filled in from the right, so once one parameter has a
+		// default every later one needs one as well
+		switch param.(type) {
+
+		case *ast.DefaultPattern:
+			hasDefault = true
+
+		case *ast.RestPattern:
+			// always last, parseParam makes sure of that
+
+		default:
+			if hasDefault {
+
+				msg := fmt.Sprintf("parameter %s needs a default value because an earlier parameter has one", param.String())
+				p.addError(tok, nil, msg)
+
+				return nil
+
+			}
+
+		}
+
+		params = append(params, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+
+		p.nextToken()
+
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+
+		return nil
+
+	}
+
+	return params
+
+}
+
+// parseParam parses a single function parameter: a name or destructuring
+// pattern with an optional default, or a trailing ...rest
+func (p *Parser) parseParam() ast.Pattern {
+
+	if !p.peekTokenIs(token.ELLIPSIS) {
+
+		param := p.parseBinding()
+
+		if param == nil {
+			return nil
+		}
+
+		return p.parseDefault(param)
+
+	}
+
+	p.nextToken()
+
+	rest := &ast.RestPattern{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	rest.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.peekTokenIs(token.RPAREN) {
+
+		p.addError(p.peekToken, []token.TokenType{token.RPAREN}, "the rest parameter must be the last parameter")
+		return nil
+
+	}
+
+	return rest
+
+}
+
+// parseBinding parses the name or destructuring pattern that follows the
+// current token in a let statement or parameter list. It returns nil after
+// recording an error if there is none.
+func (p *Parser) parseBinding() ast.Pattern {
+
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+
+		p.nextToken()
+		return p.parsePattern()
+
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+
+	block := &ast.BlockStatement{Token: p.curToken}
+
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+
+		start := p.curToken.Pos
+		stmt := p.parseStatement()
+		block.Statements = append(block.Statements, stmt)
+
+		if p.synchronize(start) {
+			continue
+		}
+
+		p.nextToken()
+
+		if p.curTokenIs(token.SEMI) {
+
+			p.nextToken()
+
+		}
+
+	}
+
+	if !p.curTokenIs(token.RBRACE) {
+
+		msg := fmt.Sprintf("expected next token to be %s, got %s instead", token.RBRACE, p.curToken.Type)
+		p.addError(p.curToken, []token.TokenType{token.RBRACE}, msg)
+
+		return block
+
+	}
+
+	block.Rbrace = p.curToken.Pos
+
+	return block
+
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+
+	expr := &ast.InfixExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Operator: p.curToken.Literal,
+	}
+
+	pr := p.curPrecedence()
+
+	// ** is right associative: 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if p.curTokenIs(token.POWER) {
+		pr--
+	}
+
+	expr.Right = p.parseOperand(pr)
+
+	return expr
+
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+
+	expr := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	// assignment is right associative: a = b = c is a = (b = c)
+	expr.Value = p.parseOperand(ASSIGN - 1)
+
+	if !isAssignable(target) {
+
+		msg := fmt.Sprintf("cannot assign to %s", target.String())
+		p.addError(expr.Token, nil, msg)
+
+		return p.badExpression(target.Pos())
+
+	}
+
+	return expr
+
+}
+
+// isAssignable reports whether exp can appear on the left of an assignment
+func isAssignable(exp ast.Expression) bool {
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return true
+	case *ast.IndexExpression:
+		return !exp.Optional
+	case *ast.MemberExpression:
+		return !exp.Optional
+	}
+
+	return false
+
+}
+
+// parseConditionalExpression parses the rest of cond ? a : b. The ':' is
+// consumed here, so a ternary inside a hash literal or keyword argument never
+// gets confused with the ':' that separates keys from values.
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+
+	exp := &ast.ConditionalExpression{Token: p.curToken, Condition: condition}
+
+	exp.Consequence = p.parseOperand(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return p.badExpression(condition.Pos())
+	}
+
+	// right associative, so a ? b : c ? d : e is a ? b : (c ? d : e)
+	exp.Alternative = p.parseOperand(TERNARY - 1)
+
+	return exp
+
+}
+
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return p.badExpression(left.Pos())
+	}
+
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+
+}
+
+// parseOptionalChain parses ?.property, ?.[index] and ?.(args), which
+// evaluate to null instead of failing when the left side is null
+func (p *Parser) parseOptionalChain(left ast.Expression) ast.Expression {
+
+	switch p.peekToken.Type {
+
+	case token.IDENT:
+		exp := p.parseMemberExpression(left)
+
+		if member, ok := exp.(*ast.MemberExpression); ok {
+			member.Optional = true
+		}
+
+		return exp
+
+	case token.LBRACKET:
+		p.nextToken()
+
+		exp := p.parseIndexExpression(left)
+
+		if index, ok := exp.(*ast.IndexExpression); ok {
+			index.Optional = true
+		}
+
+		return exp
+
+	case token.LPAREN:
+		p.nextToken()
+
+		exp := p.parseCallExpression(left)
+
+		if call, ok := exp.(*ast.CallExpression); ok {
+			call.Optional = true
+		}
+
+		return exp
+
+	}
+
+	msg := fmt.Sprintf("expected a name, [ or ( after ?., got %s instead", p.peekToken.Type)
+	p.addError(p.peekToken, []token.TokenType{token.IDENT, token.LBRACKET, token.LPAREN}, msg)
+
+	return p.badExpression(left.Pos())
+
+}
+
+func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
+
+	exp := &ast.CallExpression{Token: p.curToken, Function: fn}
+	exp.Arguments = p.parseCallArguments()
+
+	if exp.Arguments == nil {
+		return p.badExpression(fn.Pos())
+	}
+
+	exp.Rparen = p.curToken.Pos
+
+	return exp
+
+}
+
+// parseCallArguments parses the arguments of a call up to the closing ')'.
+// Keyword arguments may follow the positional ones.
+func (p *Parser) parseCallArguments() []ast.Expression {
+
+	args := []ast.Expression{}
+	errs := p.parseErrors
+
+	if p.peekTokenIs(token.RPAREN) {
+
+		p.nextToken()
+		return args
+
+	}
+
+	keywords := false
+
+	for {
+
+		tok := p.peekToken
+		arg := p.parseCallArgument()
+
+		if _, ok := arg.(*ast.KeywordArgument); ok {
+			keywords = true
+		} else if keywords {
+
+			p.addError(tok, nil, "positional argument follows keyword argument")
+			return nil
+
+		}
+
+		args = append(args, arg)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+
+		p.nextToken()
+
+	}
+
+	if !p.expectPeekAfter(token.RPAREN, errs) {
+
+		return nil
+
+	}
+
+	return args
+
+}
+
+// parseCallArgument parses a single argument, which is a keyword argument if
+// it starts with a name followed by ':'
+func (p *Parser) parseCallArgument() ast.Expression {
+
+	if !p.peekTokenIs(token.IDENT) {
+		return p.parseOperand(LOWEST)
+	}
+
+	p.nextToken()
+
+	if !p.peekTokenIs(token.COLON) {
+		return p.parseExpression(LOWEST)
+	}
+
+	arg := &ast.KeywordArgument{Token: p.curToken}
+	arg.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.nextToken()
+
+	arg.Value = p.parseOperand(LOWEST)
+
+	return arg
+
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+
+	list := []ast.Expression{}
+	errs := p.parseErrors
+
+	if p.peekTokenIs(end) {
+
+		p.nextToken()
+		return list
+
+	}
+
+	list = append(list, p.parseOperand(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+
+		p.nextToken()
+		list = append(list, p.parseOperand(LOWEST))
+
+	}
+
+	if !p.expectPeekAfter(end, errs) {
+
+		return nil
+
+	}
+
+	return list
+
+}
+
+func (p *Parser) nextToken() {
+	p.curToken = p.peekToken
+	p.peekToken = p.l.NextToken()
+
+	p.trackDelimiter(p.curToken)
+
+	// Surface anything the lexer ran into while producing peekToken
+	for _, err := range p.l.Errors()[p.lexerErrors:] {
+
+		p.errors = append(p.errors, &ParseError{
+			Pos: p.l.File().Position(err.Pos),
+			Got: p.peekToken,
+			Msg: err.Msg,
+		})
+
+	}
+
+	p.lexerErrors = len(p.l.Errors())
+}
+
+func (p *Parser) curTokenIs(t token.TokenType) bool {
+	return p.curToken.Type == t
+}
+
+func (p *Parser) peekPrecedence() int {
+
+	if pr, ok := precedences[p.peekToken.Type]; ok {
+		return pr
+	}
+
+	return LOWEST
+
+}
+
+func (p *Parser) curPrecedence() int {
+
+	if pr, ok := precedences[p.curToken.Type]; ok {
+		return pr
+	}
+
+	return LOWEST
+
+}
+
+func (p *Parser) peekTokenIs(t token.TokenType) bool {
+	return p.peekToken.Type == t
 }
 
 func (p *Parser) expectPeek(t token.TokenType) bool {
@@ -456,14 +1334,52 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-func (p *Parser) Errors() []string {
+// expectPeekAfter is expectPeek for the token that has to follow an operand.
+// errs is the parse error count from before the operand; if the operand itself
+// failed, the missing token is fallout from that and is not reported again.
+func (p *Parser) expectPeekAfter(t token.TokenType, errs int) bool {
+
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
+	}
+
+	if p.parseErrors == errs {
+		p.peekError(t)
+	}
+
+	return false
+
+}
+
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
+// addError records a ParseError located at the start of tok. Only the first
+// error at any given position is kept, later ones are almost always fallout.
+func (p *Parser) addError(tok token.Token, expected []token.TokenType, msg string) {
+
+	p.parseErrors++
+
+	if n := len(p.errors); n > 0 && p.errors[n-1].Got.Pos == tok.Pos {
+		return
+	}
+
+	p.errors = append(p.errors, &ParseError{
+		Pos:      p.l.File().Position(tok.Pos),
+		Expected: expected,
+		Got:      tok,
+		Msg:      msg,
+		Hint:     hintFor(expected, tok, p.isUnmatched(tok)),
+	})
+
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
 
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, []token.TokenType{t}, msg)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -472,10 +1388,19 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
+		start := p.curToken.Pos
 		stmt := p.parseStatement() //Parse the current statement
 
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt) //Add to program statements
+		program.Statements = append(program.Statements, stmt) //Add to program statements
+
+		//Skip past the rest of the statement if it was broken
+		if p.synchronize(start) {
+			//Nothing is open at the top level, so a '}' here belongs to the broken statement
+			for p.curTokenIs(token.RBRACE) || p.curTokenIs(token.SEMI) {
+				p.nextToken()
+			}
+
+			continue
 		}
 
 		p.nextToken() //Advance to next token
@@ -485,35 +1410,112 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 }
 
+// statementStarts holds the keywords that always begin a new statement, which
+// makes them safe places to resume parsing after an error
+var statementStarts = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.FOR:      true,
+}
+
+// synchronize resynchronizes the parser if the statement beginning at start
+// produced errors that no nested block has already recovered from. It skips
+// to where the next statement can begin: just past a ';', or at a '}', a
+// statement keyword or EOF, and reports whether it did so. This keeps one
+// mistake from producing a cascade of follow-on errors while still reporting
+// independent errors later in the input.
+func (p *Parser) synchronize(start token.Pos) bool {
+
+	if p.parseErrors == p.handledErrors {
+		return false
+	}
+
+	p.handledErrors = p.parseErrors
+
+	//A statement always spans at least its first token
+	if p.curToken.Pos == start {
+		p.nextToken()
+	}
+
+	for {
+
+		switch {
+
+		case p.curTokenIs(token.SEMI):
+			p.nextToken()
+			return true
+
+		case p.curTokenIs(token.RBRACE), p.curTokenIs(token.EOF), statementStarts[p.curToken.Type]:
+			return true
+
+		}
+
+		p.nextToken()
+
+	}
+
+}
+
+// badExpression builds a placeholder for an expression that failed to parse,
+// spanning from start to the end of the current token
+func (p *Parser) badExpression(start token.Pos) ast.Expression {
+	return &ast.BadExpression{Token: p.curToken, From: start, To: p.curToken.End}
+}
+
+// badStatement builds a placeholder for a statement starting at start that
+// failed to parse
+func (p *Parser) badStatement(start token.Token) ast.Statement {
+	return &ast.BadStatement{Token: start, From: start.Pos, To: p.curToken.End}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+func (p *Parser) parseLetStatement() ast.Statement {
 
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	binding := p.parseBinding()
+
+	if binding == nil {
+		return p.badStatement(stmt.Token)
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if ident, ok := binding.(*ast.Identifier); ok {
+		stmt.Name = ident
+	} else {
+		stmt.Pattern = binding
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
-		return nil
+		return p.badStatement(stmt.Token)
 	}
 
-	p.nextToken()
-	stmt.Value = p.parseExpression(LOWEST)
+	stmt.Value = p.parseOperand(LOWEST)
 
-	if !p.curTokenIs(token.SEMI) {
+	// name the function so runtime errors can refer to it
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fn.Name = stmt.Name.Value
+	}
+
+	if p.peekTokenIs(token.SEMI) {
 		p.nextToken()
 	}
 
@@ -521,15 +1523,90 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 }
 
-func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+func (p *Parser) parseReturnStatement() ast.Statement {
 
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
-	p.nextToken()
+	stmt.ReturnValue = p.parseOperand(LOWEST)
+
+	if p.peekTokenIs(token.SEMI) {
+		p.nextToken()
+	}
+
+	return stmt
+
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return p.badStatement(stmt.Token)
+	}
+
+	errs := p.parseErrors
+	stmt.Condition = p.parseOperand(LOWEST)
+
+	if !p.expectPeekAfter(token.RPAREN, errs) {
+		return p.badStatement(stmt.Token)
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return p.badStatement(stmt.Token)
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMI) {
+		p.nextToken()
+	}
+
+	return stmt
+
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+		return p.badStatement(stmt.Token)
+	}
+
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+
+		p.nextToken()
 
-	stmt.ReturnValue = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.IDENT) {
+			return p.badStatement(stmt.Token)
+		}
+
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	}
+
+	if !p.expectPeek(token.IN) {
+		return p.badStatement(stmt.Token)
+	}
+
+	errs := p.parseErrors
+	stmt.Iterable = p.parseOperand(LOWEST)
+
+	if !p.expectPeekAfter(token.RPAREN, errs) || !p.expectPeek(token.LBRACE) {
+		return p.badStatement(stmt.Token)
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
 
-	if !p.curTokenIs(token.SEMI) {
+	if p.peekTokenIs(token.SEMI) {
 		p.nextToken()
 	}
 
@@ -537,6 +1614,33 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 }
 
+// parseLoopControlStatement parses break and continue, which are only valid
+// inside a loop in the current function
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+
+	tok := p.curToken
+
+	if p.peekTokenIs(token.SEMI) {
+		p.nextToken()
+	}
+
+	if p.loopDepth == 0 {
+
+		msg := fmt.Sprintf("%s is not inside a loop", tok.Literal)
+		p.addError(tok, nil, msg)
+
+		return p.badStatement(tok)
+
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+
+	return &ast.ContinueStatement{Token: tok}
+
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
@@ -559,8 +1663,8 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 	if prefix == nil {
 
-		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		p.noPrefixParseFnError(p.curToken)
+		return p.badExpression(p.curToken.Pos)
 
 	}
 
@@ -586,10 +1690,85 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+// closingTokens can never start an expression. They are left unconsumed when
+// an operand is missing so the construct they close can still finish normally.
+var closingTokens = map[token.TokenType]bool{
+	token.RPAREN:   true,
+	token.RBRACKET: true,
+	token.RBRACE:   true,
+}
+
+// closers maps opening delimiters to the token that closes them
+var closers = map[token.TokenType]token.TokenType{
+	token.LPAREN:   token.RPAREN,
+	token.LBRACKET: token.RBRACKET,
+	token.LBRACE:   token.RBRACE,
+}
+
+// trackDelimiter updates openDelims for tok, which has just become the current
+// token
+func (p *Parser) trackDelimiter(tok token.Token) {
+
+	if closer, ok := closers[tok.Type]; ok {
+		p.openDelims[closer]++
+		return
+	}
+
+	if !closingTokens[tok.Type] {
+		return
+	}
+
+	if p.openDelims[tok.Type] == 0 {
+		p.strayCloser = tok.Pos
+		return
+	}
+
+	p.openDelims[tok.Type]--
+
+}
+
+// isUnmatched reports whether tok, the current or the peek token, is a closing
+// delimiter with no opening delimiter before it
+func (p *Parser) isUnmatched(tok token.Token) bool {
+
+	if !closingTokens[tok.Type] {
+		return false
+	}
+
+	// the current token has already been counted by trackDelimiter
+	if tok.Pos == p.curToken.Pos {
+		return tok.Pos == p.strayCloser
+	}
+
+	return p.openDelims[tok.Type] == 0
+
+}
+
+// parseOperand advances to the next token and parses an expression from it
+func (p *Parser) parseOperand(precedence int) ast.Expression {
+
+	if closingTokens[p.peekToken.Type] {
+
+		p.noPrefixParseFnError(p.peekToken)
+		return &ast.BadExpression{Token: p.peekToken, From: p.peekToken.Pos, To: p.peekToken.Pos}
+
+	}
+
+	p.nextToken()
+
+	return p.parseExpression(precedence)
+
+}
+
+func (p *Parser) noPrefixParseFnError(tok token.Token) {
+
+	msg := fmt.Sprintf("No prefix parse function for %s was found", tok.Type)
+
+	if tok.Type == token.ILLEGAL {
+		msg = fmt.Sprintf("Unexpected character %q", tok.Literal)
+	}
 
-	msg := fmt.Sprintf("No prefix parse function for %s was found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(tok, nil, msg)
 
 }
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/Sheep42/Monkey-Lang/ast"
 	"github.com/Sheep42/Monkey-Lang/lexer"
+	"github.com/Sheep42/Monkey-Lang/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -186,6 +187,181 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralForms(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0", 0},
+		{"1_000_000", 1000000},
+		{"0xFF", 255},
+		{"0Xff_ff", 65535},
+		{"0o755", 493},
+		{"0b1010", 10},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+
+		if !ok {
+			t.Fatalf("Expression was incorrect type. Expected=\"*ast.IntegerLiteral\". Got=\"%T\"", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("Literal value was incorrect. Expected=%d. Got=%d", tt.expected, literal.Value)
+		}
+
+		if literal.String() != tt.input {
+			t.Errorf("String() did not keep the original spelling. Expected=%q. Got=%q", tt.input, literal.String())
+		}
+
+	}
+}
+
+func TestBigIntegerLiteral(t *testing.T) {
+
+	input := "0x1_0000_0000_0000_0000"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+
+	if !ok {
+		t.Fatalf("Expression was incorrect type. Expected=\"*ast.IntegerLiteral\". Got=\"%T\"", stmt.Expression)
+	}
+
+	if literal.Big == nil || literal.Big.String() != "18446744073709551616" {
+		t.Errorf("literal.Big was incorrect. Expected=18446744073709551616. Got=%v", literal.Big)
+	}
+
+	if literal.String() != input {
+		t.Errorf("String() did not keep the original spelling. Expected=%q. Got=%q", input, literal.String())
+	}
+}
+
+func TestRationalLiteralExpression(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1r", "1/1"},
+		{"0.25r", "1/4"},
+		{"1_000r", "1000/1"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.RationalLiteral)
+
+		if !ok {
+			t.Fatalf("Expression was incorrect type. Expected=\"*ast.RationalLiteral\". Got=\"%T\"", stmt.Expression)
+		}
+
+		if literal.Value.String() != tt.expected {
+			t.Errorf("Literal value was incorrect. Expected=%s. Got=%s", tt.expected, literal.Value)
+		}
+
+		if literal.String() != tt.input {
+			t.Errorf("String() did not keep the original spelling. Expected=%q. Got=%q", tt.input, literal.String())
+		}
+
+	}
+}
+
+func TestMalformedNumberLiterals(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"0x", `hexadecimal literal "0x" has no digits`},
+		{"0b", `binary literal "0b" has no digits`},
+		{"0b102", `invalid digit '2' in binary literal "0b102"`},
+		{"0o78", `invalid digit '8' in octal literal "0o78"`},
+		{"0xFG", `invalid digit 'G' in hexadecimal literal "0xFG"`},
+		{"1__000", `'_' must separate successive digits in "1__000"`},
+		{"100_", `'_' must separate successive digits in "100_"`},
+		{"1_.5", `'_' must separate successive digits in "1_.5"`},
+		{"0755", `leading zeros are not allowed in decimal literal "0755"; use the 0o prefix for octal`},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+
+		if len(errors) != 1 {
+			t.Errorf("Wrong number of errors for %q. Expected=1. Got=%d", tt.input, len(errors))
+			continue
+		}
+
+		if errors[0].Msg != tt.expectedMsg {
+			t.Errorf("Wrong error message for %q. Expected=%q. Got=%q", tt.input, tt.expectedMsg, errors[0].Msg)
+		}
+
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"0.5", 0.5},
+		{"1e-9", 1e-9},
+		{"2.5E3", 2500},
+		{"1_000.000_1", 1000.0001},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+
+		if !ok {
+			t.Fatalf("Expression was incorrect type. Expected=\"*ast.FloatLiteral\". Got=\"%T\"", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("Literal value was incorrect. Expected=%g. Got=%g", tt.expected, literal.Value)
+		}
+
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() was incorrect. Expected=%q. Got=%q", tt.input, literal.String())
+		}
+
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 
 	tests := []struct {
@@ -194,6 +370,8 @@ func TestStringLiteralExpression(t *testing.T) {
 	}{
 		{`"hello, world"`, "hello, world"},
 		{`'hello, world'`, "hello, world"},
+		{`"hello,\tworld\n"`, "hello,\tworld\n"},
+		{"`hello,\\tworld`", `hello,\tworld`},
 	}
 	for _, tt := range tests {
 
@@ -461,6 +639,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
 		// {"foobar + barfoo;", "foobar", "+", "barfoo"},
 		// {"foobar - barfoo;", "foobar", "-", "barfoo"},
 		// {"foobar * barfoo;", "foobar", "*", "barfoo"},
@@ -626,6 +815,158 @@ func TestParsingOperatorPrecedence(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a == b && c < d || e",
+			"(((a == b) && (c < d)) || e)",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"a | b & c",
+			"(a | (b & c))",
+		},
+		{
+			"a ^ b << c",
+			"(a ^ (b << c))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"a << b + c",
+			"((a << b) + c)",
+		},
+		{
+			"~a & b",
+			"((~a) & b)",
+		},
+		{
+			"a ? b : c",
+			"(a ? b : c)",
+		},
+		{
+			"a || b ? c + 1 : d",
+			"((a || b) ? (c + 1) : d)",
+		},
+		{
+			"a ? b : c ? d : e",
+			"(a ? b : (c ? d : e))",
+		},
+		{
+			"a ? b ? c : d : e",
+			"(a ? (b ? c : d) : e)",
+		},
+		{
+			"x = a ? b : c",
+			"(x = (a ? b : c))",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"a ?? b || c",
+			"(a ?? (b || c))",
+		},
+		{
+			"a ?? b ? c : d",
+			"((a ?? b) ? c : d)",
+		},
+		{
+			"h?.[\"k\"]?.(x)[0]",
+			"((h?.[k])?.(x)[0])",
+		},
+		{
+			"-f?.(a) + 1",
+			"((-f?.(a)) + 1)",
+		},
+		{
+			"person.address.city",
+			"person.address.city",
+		},
+		{
+			"-a.b * c.d",
+			"((-a.b) * c.d)",
+		},
+		{
+			"arr.push(4).len()",
+			"arr.push(4).len()",
+		},
+		{
+			"h.items[0].name",
+			"(h.items[0]).name",
+		},
+		{
+			"h?.a.b ?? c",
+			"(h?.a.b ?? c)",
+		},
+		{
+			"a.b = 1 + 2",
+			"(a.b = (1 + 2))",
+		},
+		{
+			"a |> f() |> g(b)",
+			"((a |> f()) |> g(b))",
+		},
+		{
+			"a + b |> f == c",
+			"((a + b) |> (f == c))",
+		},
+		{
+			"a |> f && b |> g",
+			"((a |> f) && (b |> g))",
+		},
+		{
+			"a |> f >> g",
+			"(a |> (f >> g))",
+		},
+		{
+			"x = y = 5",
+			"(x = (y = 5))",
+		},
+		{
+			"x += a * b || c",
+			"(x += ((a * b) || c))",
+		},
+		{
+			"x -= 1; y *= 2; z /= 3; w %= 4",
+			"(x -= 1)(y *= 2)(z /= 3)(w %= 4)",
+		},
+		{
+			"a[i + 1] = b[0] * 2",
+			"((a[(i + 1)]) = ((b[0]) * 2))",
+		},
+		{
+			"h[\"k\"][0] += 1",
+			"(((h[k])[0]) += 1)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -647,6 +988,62 @@ func TestParsingOperatorPrecedence(t *testing.T) {
 
 }
 
+func TestAssignExpression(t *testing.T) {
+
+	l := lexer.New("count += 1;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assign, ok := stmt.Expression.(*ast.AssignExpression)
+
+	if !ok {
+		t.Fatalf("Expression is not *ast.AssignExpression. got=%T", stmt.Expression)
+	}
+
+	if assign.Operator != "+=" {
+		t.Errorf("assign.Operator is not %q. got=%q", "+=", assign.Operator)
+	}
+
+	testIdentifier(t, assign.Target, "count")
+	testIntegerLiteral(t, assign.Value, 1)
+
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"5 = 3", "cannot assign to 5"},
+		{"a + b = 3", "cannot assign to (a + b)"},
+		{"f() += 1", "cannot assign to f()"},
+		{"[1][0] + 1 = 2", "cannot assign to (([1][0]) + 1)"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 1 || p.Errors()[0].Msg != tt.expectedMsg {
+			t.Errorf("Wrong errors for %q. Expected=%q. Got=%v", tt.input, tt.expectedMsg, p.Errors())
+			continue
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		if _, ok := stmt.Expression.(*ast.BadExpression); !ok {
+			t.Errorf("Expression is not *ast.BadExpression. got=%T", stmt.Expression)
+		}
+
+	}
+
+}
+
 func TestBooleanExpression(t *testing.T) {
 
 	tests := []struct {
@@ -868,58 +1265,353 @@ func TestIfElseExpression(t *testing.T) {
 
 }
 
-func TestFunctionLiteralParsing(t *testing.T) {
+func TestElseIfExpression(t *testing.T) {
 
-	input := `fn(x, y) { x + y; }`
+	input := `if (x < y) { x } else if (x > y) { y } else if (x == 0) { 0 } else { 1 }`
 
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
-
 	checkParserErrors(t, p)
 
 	if len(program.Statements) != 1 {
-
-		t.Fatalf("Program has incorrect number of statements. Expected=%d. Got=%d", 1, len(program.Statements))
-
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
 	}
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
 
 	if !ok {
+		t.Fatalf("Expression is not *ast.IfExpression. got=%T", program.Statements[0])
+	}
 
-		t.Fatalf("Statement is of incorrect type. Expected=%s. Got=%T", "*ast.ExpressionStatement", program.Statements[0])
-
+	if exp.Alternative != nil {
+		t.Errorf("exp.Alternative was not nil. got=%+v", exp.Alternative)
 	}
 
-	fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if exp.ElseIf == nil || exp.ElseIf.ElseIf == nil {
+		t.Fatalf("else if chain is too short. got=%s", exp)
+	}
 
-	if !ok {
+	if !testInfixExpression(t, exp.ElseIf.Condition, "x", ">", "y") {
+		return
+	}
 
-		t.Fatalf("Expression is of incorrect type. Expected=%s. Got=%T", "*ast.FunctionLiteral", stmt.Expression)
+	last := exp.ElseIf.ElseIf
 
+	if last.Alternative == nil || last.ElseIf != nil {
+		t.Fatalf("last link does not end in else. got=%s", last)
 	}
 
-	if len(fn.Parameters) != 2 {
-
-		t.Fatalf("Function has incorrect number of params. Expected=%d. Got=%d", 2, len(fn.Parameters))
+	expected := "if(x < y) xelse if(x > y) yelse if(x == 0) 0else 1"
 
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. expected=%q. got=%q", expected, exp.String())
 	}
 
-	testLiteralExpression(t, fn.Parameters[0], "x")
-	testLiteralExpression(t, fn.Parameters[1], "y")
-
-	if len(fn.Body.Statements) != 1 {
+	if end := l.File().Offset(exp.End()); end != len(input) {
+		t.Errorf("exp.End() wrong. expected=%d. got=%d", len(input), end)
+	}
 
-		t.Fatalf("Function body has incorrect number of statements. Expected=%d. Got=%d", 1, len(fn.Body.Statements))
+	l = lexer.New("if (a) { 1 } else if { 2 }")
+	p = New(l)
+	p.ParseProgram()
 
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error for an else if without a condition")
 	}
 
-	body, ok := fn.Body.Statements[0].(*ast.ExpressionStatement)
+}
 
-	if !ok {
+func TestWhileStatement(t *testing.T) {
 
-		t.Fatalf("Body statement is of incorrect type. Expected=%s. Got=%T", "*ast.ExpressionStatement", fn.Body.Statements[0])
+	input := `while (x < 10) { if (x == 5) { break; } x += 1; continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("Body does not contain %d statements. got=%d", 3, len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("Body.Statements[2] is not *ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+
+	ifExp := stmt.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+
+	if _, ok := ifExp.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("if consequence is not *ast.BreakStatement. got=%T", ifExp.Consequence.Statements[0])
+	}
+
+	expected := "while(x < 10) if(x == 5) break;(x += 1)continue;"
+
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q. got=%q", expected, program.String())
+	}
+
+}
+
+func TestForStatement(t *testing.T) {
+
+	tests := []struct {
+		input            string
+		expectedKey      string
+		expectedValue    string
+		expectedIterable string
+		expectedString   string
+	}{
+		{"for (x in arr) { puts(x); }", "", "x", "arr", "for (x in arr) puts(x)"},
+		{"for (k, v in h) { if (k) { continue; } }", "k", "v", "h", "for (k, v in h) ifk continue;"},
+		{"for (i in range(1, 5)) { break }", "", "i", "range(1, 5)", "for (i in range(1, 5)) break;"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		if tt.expectedKey == "" && stmt.Key != nil {
+			t.Errorf("stmt.Key is not nil. got=%s", stmt.Key)
+		}
+
+		if tt.expectedKey != "" {
+			testIdentifier(t, stmt.Key, tt.expectedKey)
+		}
+
+		testIdentifier(t, stmt.Value, tt.expectedValue)
+
+		if stmt.Iterable.String() != tt.expectedIterable {
+			t.Errorf("stmt.Iterable wrong. expected=%q. got=%q", tt.expectedIterable, stmt.Iterable.String())
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q. got=%q", tt.expectedString, stmt.String())
+		}
+
+	}
+
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"break;", "break is not inside a loop"},
+		{"if (true) { continue; }", "continue is not inside a loop"},
+		{"while (true) { let f = fn() { break; }; }", "break is not inside a loop"},
+		{"while (true) { } continue", "continue is not inside a loop"},
+		{"for (x in y) { fn() { continue } }", "continue is not inside a loop"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) != 1 || p.Errors()[0].Msg != tt.expectedMsg {
+			t.Errorf("Wrong errors for %q. Expected=%q. Got=%v", tt.input, tt.expectedMsg, p.Errors())
+		}
+
+	}
+
+}
+
+func TestMatchExpression(t *testing.T) {
+
+	tests := []struct {
+		input          string
+		expectedArms   int
+		expectedString string
+	}{
+		{"match (x) { 1 => \"one\", _ => \"other\" }", 2, "match (x) { 1 => one, _ => other }"},
+		{"match (x) { -1 => a, 2.5 => b, 1r => c, true => d, }", 4, "match (x) { (-1) => a, 2.5 => b, 1r => c, true => d }"},
+		{"match (xs) { [] => 0, [h, ...t] => h, [...] => 1 }", 3, "match (xs) { [] => 0, [h, ...t] => h, [...] => 1 }"},
+		{"match (h) { {\"a\": [x, _], 1: y} => x + y }", 1, "match (h) { {a: [x, _], 1: y} => (x + y) }"},
+		{"match (n) { x if x > 0 => x, _ => 0 }", 2, "match (n) { x if (x > 0) => x, _ => 0 }"},
+		{"match (n) { 0 => { let y = 1; y } _ => { 2 } }", 2, "match (n) { 0 => let y = 1;y, _ => 2 }"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.MatchExpression)
+
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.MatchExpression. got=%T", stmt.Expression)
+		}
+
+		if len(exp.Arms) != tt.expectedArms {
+			t.Errorf("wrong number of arms. expected=%d. got=%d", tt.expectedArms, len(exp.Arms))
+		}
+
+		if exp.String() != tt.expectedString {
+			t.Errorf("exp.String() wrong. expected=%q. got=%q", tt.expectedString, exp.String())
+		}
+
+	}
+
+}
+
+func TestMalformedMatchPatterns(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"match (x) { x + 1 => 2 }", "expected next token to be =>, got + instead"},
+		{"match (x) { fn => 2 }", "expected a pattern, got FUNCTION"},
+		{"match (x) { [...t, h] => 2 }", "expected next token to be ], got , instead"},
+		{"match (x) { {[1]: v} => 2 }", "hash pattern keys must be literals, got [1]"},
+		{"match (x) { 1 => 1 2 => 2 }", "expected next token to be ,, got INT instead"},
+		{"match (1) { 0x => 1 }", `hexadecimal literal "0x" has no digits`},
+		{"match (1) { -0x => 1 }", `hexadecimal literal "0x" has no digits`},
+		{"match (1) { [0x] => 1 }", `hexadecimal literal "0x" has no digits`},
+		{"match (1) { {0x: a} => 1 }", `hexadecimal literal "0x" has no digits`},
+		{"let [0b2] = 1", `invalid digit '2' in binary literal "0b2"`},
+		{"let {0x: a} = 1", `hexadecimal literal "0x" has no digits`},
+		{"let f = fn([0x]) { 1 }", `hexadecimal literal "0x" has no digits`},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0].Msg != tt.expectedMsg {
+			t.Errorf("Wrong errors for %q. Expected=%q. Got=%v", tt.input, tt.expectedMsg, p.Errors())
+		}
+
+		// the partial AST must not hold nil patterns
+		_ = program.String()
+
+	}
+
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"let [a, b] = arr;", "let [a, b] = arr;"},
+		{"let [first, ...rest] = f();", "let [first, ...rest] = f();"},
+		{"let [a, b = 2, ...] = arr", "let [a, b = 2, ...] = arr;"},
+		{"let {name, age: years} = person;", "let {name, age: years} = person;"},
+		{"let {name = \"anon\", \"k\": [x, y] = [1, 2]} = h;", "let {name = anon, k: [x, y] = [1, 2]} = h;"},
+		{"let [{id}, [_, z]] = pairs;", "let [{id}, [_, z]] = pairs;"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Errorf("stmt does not destructure. Name=%v. Pattern=%v", stmt.Name, stmt.Pattern)
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q. got=%q", tt.expectedString, stmt.String())
+		}
+
+	}
+
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+
+	input := `fn(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+
+		t.Fatalf("Program has incorrect number of statements. Expected=%d. Got=%d", 1, len(program.Statements))
+
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+
+		t.Fatalf("Statement is of incorrect type. Expected=%s. Got=%T", "*ast.ExpressionStatement", program.Statements[0])
+
+	}
+
+	fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+
+	if !ok {
+
+		t.Fatalf("Expression is of incorrect type. Expected=%s. Got=%T", "*ast.FunctionLiteral", stmt.Expression)
+
+	}
+
+	if len(fn.Parameters) != 2 {
+
+		t.Fatalf("Function has incorrect number of params. Expected=%d. Got=%d", 2, len(fn.Parameters))
+
+	}
+
+	testLiteralExpression(t, fn.Parameters[0].(*ast.Identifier), "x")
+	testLiteralExpression(t, fn.Parameters[1].(*ast.Identifier), "y")
+
+	if len(fn.Body.Statements) != 1 {
+
+		t.Fatalf("Function body has incorrect number of statements. Expected=%d. Got=%d", 1, len(fn.Body.Statements))
+
+	}
+
+	body, ok := fn.Body.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+
+		t.Fatalf("Body statement is of incorrect type. Expected=%s. Got=%T", "*ast.ExpressionStatement", fn.Body.Statements[0])
 
 	}
 
@@ -957,12 +1649,250 @@ func TestFunctionParamParsing(t *testing.T) {
 
 		for i, ident := range tt.expectedParams {
 
-			testLiteralExpression(t, fn.Parameters[i], ident)
+			testLiteralExpression(t, fn.Parameters[i].(*ast.Identifier), ident)
 
 		}
 	}
 }
 
+func TestDestructuringParamParsing(t *testing.T) {
+
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn([a, b]){}", expectedParams: []string{"[a, b]"}},
+		{input: "fn(x, {name, tags: [first, ...]}) {}", expectedParams: []string{"x", "{name, tags: [first, ...]}"}},
+		{input: "fn([x = 0, y = x]) {}", expectedParams: []string{"[x = 0, y = x]"}},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		fn := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(fn.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length of params is incorrect. Expected=%d. Got=%d", len(tt.expectedParams), len(fn.Parameters))
+		}
+
+		for i, param := range tt.expectedParams {
+
+			if fn.Parameters[i].String() != param {
+				t.Errorf("param %d wrong. Expected=%q. Got=%q", i, param, fn.Parameters[i].String())
+			}
+
+		}
+	}
+}
+
+func TestDefaultAndRestParamParsing(t *testing.T) {
+
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"fn(a, b = 2) {}", "fn(a, b = 2) "},
+		{"fn(a = 1, b = a + 1) {}", "fn(a = 1, b = (a + 1)) "},
+		{"fn(first, ...rest) {}", "fn(first, ...rest) "},
+		{"fn([x, y] = [0, 0], ...more) {}", "fn([x, y] = [0, 0], ...more) "},
+		{"fn(...all) {}", "fn(...all) "},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		if stmt.Expression.String() != tt.expectedString {
+			t.Errorf("String() wrong. expected=%q. got=%q", tt.expectedString, stmt.Expression.String())
+		}
+
+	}
+
+}
+
+func TestMalformedParams(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"fn(a = 1, b) {}", "parameter b needs a default value because an earlier parameter has one"},
+		{"fn(...rest, a) {}", "the rest parameter must be the last parameter"},
+		{"fn(...) {}", "expected next token to be IDENT, got ) instead"},
+		{"fn(...rest = []) {}", "the rest parameter must be the last parameter"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0].Msg != tt.expectedMsg {
+			t.Errorf("Wrong errors for %q. Expected=%q. Got=%v", tt.input, tt.expectedMsg, p.Errors())
+		}
+
+	}
+
+}
+
+func TestFunctionLiteralName(t *testing.T) {
+
+	l := lexer.New("let add = fn(a, b) { a + b }; let x = 1 + 1; fn() {}")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+
+	if fn.Name != "add" {
+		t.Errorf("fn.Name wrong. expected=%q. got=%q", "add", fn.Name)
+	}
+
+	anon := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+
+	if anon.Name != "" {
+		t.Errorf("anonymous fn.Name is not empty. got=%q", anon.Name)
+	}
+
+}
+
+func TestKeywordArgumentParsing(t *testing.T) {
+
+	tests := []struct {
+		input          string
+		expectedString string
+		keywords       []string
+	}{
+		{"f(limit: 10, verbose: true)", "f(limit: 10, verbose: true)", []string{"limit", "verbose"}},
+		{"f(1, x + 1, step: 2 * 3)", "f(1, (x + 1), step: (2 * 3))", []string{"", "", "step"}},
+		{"f(a, b)", "f(a, b)", []string{"", ""}},
+		{"f(h: {\"k\": 1})", "f(h: {k:1})", []string{"h"}},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp := stmt.Expression.(*ast.CallExpression)
+
+		if exp.String() != tt.expectedString {
+			t.Errorf("exp.String() wrong. expected=%q. got=%q", tt.expectedString, exp.String())
+		}
+
+		if len(exp.Arguments) != len(tt.keywords) {
+			t.Fatalf("wrong number of arguments. expected=%d. got=%d", len(tt.keywords), len(exp.Arguments))
+		}
+
+		for i, name := range tt.keywords {
+
+			kw, ok := exp.Arguments[i].(*ast.KeywordArgument)
+
+			if ok != (name != "") || (ok && kw.Name.Value != name) {
+				t.Errorf("argument %d wrong. expected keyword %q. got=%s", i, name, exp.Arguments[i])
+			}
+
+		}
+
+	}
+
+}
+
+func TestConditionalInsideHashAndCall(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{a ? "x" : "y": 1}`, "{(a ? x : y):1}"},
+		{`{"k": a ? 1 : 2}`, "{k:(a ? 1 : 2)}"},
+		{"f(limit: a ? 1 : 2, b)", ""},
+		{"f(x: a ? b : c)", "f(x: (a ? b : c))"},
+		{"a ? {\"k\": 1} : {}", "(a ? {k:1} : {})"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		// an empty expectation marks input that must not parse
+		if tt.expected == "" {
+
+			if len(p.Errors()) == 0 {
+				t.Errorf("expected errors for %q, got none", tt.input)
+			}
+
+			continue
+
+		}
+
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong String() for %q. expected=%q. got=%q", tt.input, tt.expected, program.String())
+		}
+
+	}
+
+}
+
+func TestMalformedNullHandling(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"a ? b", "expected next token to be :, got EOF instead"},
+		{"h?.1", "expected a name, [ or ( after ?., got INT instead"},
+		{"h?.[0] = 1", "cannot assign to (h?.[0])"},
+		{"h?.k = 1", "cannot assign to h?.k"},
+		{"h.1", "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0].Msg != tt.expectedMsg {
+			t.Errorf("Wrong errors for %q. Expected=%q. Got=%v", tt.input, tt.expectedMsg, p.Errors())
+		}
+
+	}
+
+}
+
+func TestPositionalAfterKeywordArgument(t *testing.T) {
+
+	l := lexer.New("f(a: 1, 2)")
+	p := New(l)
+	p.ParseProgram()
+
+	expected := "positional argument follows keyword argument"
+
+	if len(p.Errors()) == 0 || p.Errors()[0].Msg != expected {
+		t.Errorf("Wrong errors. Expected=%q. Got=%v", expected, p.Errors())
+	}
+
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 
 	input := `add(1, 2 * 3, 4 + 5);`
@@ -1231,3 +2161,283 @@ func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, ope
 	return true
 
 }
+
+func TestNodePositions(t *testing.T) {
+
+	input := "let add = fn(x, y) { x + y; };\nadd(1, [2, 3][0])"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	fn := letStmt.Value.(*ast.FunctionLiteral)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	index := call.Arguments[1].(*ast.IndexExpression)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart int
+		expectedEnd   int
+	}{
+		{letStmt, 0, 29},
+		{fn, 10, 29},
+		{fn.Body, 19, 29},
+		{fn.Body.Statements[0], 21, 26},
+		{call, 31, 48},
+		{index, 38, 47},
+		{index.Left, 38, 44},
+		{program, 0, 48},
+	}
+
+	file := l.File()
+
+	for _, tt := range tests {
+
+		start := file.Offset(tt.node.Pos())
+		end := file.Offset(tt.node.End())
+
+		if start != tt.expectedStart || end != tt.expectedEnd {
+			t.Errorf("Wrong span for %q. Expected=%d-%d. Got=%d-%d", tt.node.String(), tt.expectedStart, tt.expectedEnd, start, end)
+		}
+
+	}
+
+	pos := file.Position(call.Pos())
+
+	if pos.Line != 2 || pos.Column != 1 {
+		t.Errorf("Wrong position for call. Expected=2:1. Got=%s", pos)
+	}
+
+}
+
+func TestParseErrorDetails(t *testing.T) {
+
+	tests := []struct {
+		input            string
+		expectedLine     int
+		expectedColumn   int
+		expectedExpected []token.TokenType
+		expectedGot      token.TokenType
+		expectedHint     string
+	}{
+		{"let x = add(1, 2;", 1, 17, []token.TokenType{token.RPAREN}, token.SEMI, `missing closing ")"`},
+		{"[1, 2", 1, 6, []token.TokenType{token.RBRACKET}, token.EOF, `missing closing "]"`},
+		{"if (x) {\n  1", 2, 4, []token.TokenType{token.RBRACE}, token.EOF, `missing closing "}"`},
+		{"5 + @", 1, 5, nil, token.ILLEGAL, `"@" is not valid here; remove it or put it inside a string`},
+		{"5 + )", 1, 5, nil, token.RPAREN, `unmatched ")"`},
+		{"(1) )", 1, 5, nil, token.RPAREN, `unmatched ")"`},
+		{"let x = [1]; ]", 1, 14, nil, token.RBRACKET, `unmatched "]"`},
+		{"let x = {1: 2, 3}", 1, 17, []token.TokenType{token.COLON}, token.RBRACE, ""},
+		{"let x = (1 + )", 1, 14, nil, token.RPAREN, ""},
+	}
+
+	for _, tt := range tests {
+
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("Expected parser errors for %q. Got none", tt.input)
+			continue
+		}
+
+		err := errors[0]
+
+		if err.Pos.Line != tt.expectedLine || err.Pos.Column != tt.expectedColumn {
+			t.Errorf("Wrong position for %q. Expected=%d:%d. Got=%s", tt.input, tt.expectedLine, tt.expectedColumn, err.Pos)
+		}
+
+		if fmt.Sprint(err.Expected) != fmt.Sprint(tt.expectedExpected) {
+			t.Errorf("Wrong expected tokens for %q. Expected=%v. Got=%v", tt.input, tt.expectedExpected, err.Expected)
+		}
+
+		if err.Got.Type != tt.expectedGot {
+			t.Errorf("Wrong got token for %q. Expected=%s. Got=%s", tt.input, tt.expectedGot, err.Got.Type)
+		}
+
+		if err.Hint != tt.expectedHint {
+			t.Errorf("Wrong hint for %q. Expected=%q. Got=%q", tt.input, tt.expectedHint, err.Hint)
+		}
+
+	}
+
+}
+
+func TestParseErrorRender(t *testing.T) {
+
+	input := "let a = 1;\n\tlet x = add(1, 2;"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("Expected parser errors. Got none")
+	}
+
+	expected := "2:18: expected next token to be ), got ; instead\n" +
+		"    \tlet x = add(1, 2;\n" +
+		"    \t                ^\n" +
+		"    hint: missing closing \")\"\n"
+
+	if got := p.Errors()[0].Render(input); got != expected {
+		t.Errorf("Rendered error is incorrect. Expected=%q. Got=%q", expected, got)
+	}
+
+}
+
+func TestParserRecovery(t *testing.T) {
+
+	input := `let = 5;
+let x = add(1, 2;
+let y = fn(a) { a + };
+if (x) { let z = [1, 2; z } else { 3 }
+let ok = 10;`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	expectedErrors := []struct {
+		line   int
+		column int
+	}{
+		{1, 5},
+		{2, 17},
+		{3, 21},
+		{4, 23},
+	}
+
+	errors := p.Errors()
+
+	if len(errors) != len(expectedErrors) {
+
+		for _, err := range errors {
+			t.Logf("Parser Error: %s", err)
+		}
+
+		t.Fatalf("Wrong number of errors. Expected=%d. Got=%d", len(expectedErrors), len(errors))
+
+	}
+
+	for i, expected := range expectedErrors {
+
+		if errors[i].Pos.Line != expected.line || errors[i].Pos.Column != expected.column {
+			t.Errorf("errors[%d] at wrong position. Expected=%d:%d. Got=%s", i, expected.line, expected.column, errors[i].Pos)
+		}
+
+	}
+
+	if len(program.Statements) != 5 {
+		t.Fatalf("Wrong number of statements. Expected=%d. Got=%d", 5, len(program.Statements))
+	}
+
+	if _, ok := program.Statements[0].(*ast.BadStatement); !ok {
+		t.Errorf("Statements[0] is not *ast.BadStatement. Got=%T", program.Statements[0])
+	}
+
+	let := program.Statements[1].(*ast.LetStatement)
+
+	if _, ok := let.Value.(*ast.BadExpression); !ok {
+		t.Errorf("let x value is not *ast.BadExpression. Got=%T", let.Value)
+	}
+
+	ifExp := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+
+	if ifExp.Alternative == nil {
+		t.Errorf("if expression lost its else branch during recovery")
+	}
+
+	fn := program.Statements[2].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+
+	if _, ok := body.Right.(*ast.BadExpression); !ok {
+		t.Errorf("Missing operand is not *ast.BadExpression. Got=%T", body.Right)
+	}
+
+	testLetStatement(t, program.Statements[4], "ok")
+
+}
+
+func TestFailedOperandsReportOneError(t *testing.T) {
+
+	tests := []string{
+		"let a = (1 + ; let b = 2",
+		"let a = x[1 + ; let b = 2",
+		"let a = f(1, 2 * ; let b = 2",
+		"let a = [1, 2 * ; let b = 2",
+		"let a = {1: 2 * ; let b = 2",
+		"let a = if (1 + ; let b = 2",
+		"let a = match (1 + ; let b = 2",
+		"while (1 + ; let b = 2",
+		"for (x in 1 + ; let b = 2",
+	}
+
+	for _, input := range tests {
+
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Errorf("Wrong number of errors for %q. Expected=%d. Got=%v", input, 1, p.Errors())
+			continue
+		}
+
+		if len(program.Statements) != 2 {
+			t.Errorf("Wrong number of statements for %q. Expected=%d. Got=%d", input, 2, len(program.Statements))
+			continue
+		}
+
+		testLetStatement(t, program.Statements[1], "b")
+
+	}
+
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+
+	input := "let x = 5; /* unterminated"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Wrong number of errors. Expected=%d. Got=%d", 1, len(p.Errors()))
+	}
+
+	err := p.Errors()[0]
+
+	if err.Msg != "unterminated block comment" || err.Pos.Column != 12 {
+		t.Errorf("Wrong error. Expected=%q at column 12. Got=%q at column %d", "unterminated block comment", err.Msg, err.Pos.Column)
+	}
+
+	testLetStatement(t, program.Statements[0], "x")
+
+}
+
+func TestLexerErrorsDoNotSkipStatements(t *testing.T) {
+
+	input := "x\n\"\\q\"\ny"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Wrong number of errors. Expected=%d. Got=%d", 1, len(p.Errors()))
+	}
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("Wrong number of statements. Expected=%d. Got=%d", 3, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[2].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("program.Statements[2] is not *ast.ExpressionStatement. Got=%T", program.Statements[2])
+	}
+
+	testIdentifier(t, stmt.Expression, "y")
+
+}
@@ -36,7 +36,7 @@ func Start(in io.Reader, out io.Writer) {
 
 		if len(p.Errors()) != 0 {
 
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Errors())
 			continue
 
 		}
@@ -53,11 +53,11 @@ func Start(in io.Reader, out io.Writer) {
 
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, src string, errors []*parser.ParseError) {
 
-	for _, msg := range errors {
+	for _, err := range errors {
 
-		io.WriteString(out, "\t"+msg+"\n")
+		io.WriteString(out, err.Render(src))
 
 	}
 
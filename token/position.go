@@ -0,0 +1,166 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pos is a compact source position. It is only meaningful relative to the
+// FileSet that produced it, which allows several files to share one position
+// space.
+type Pos int
+
+// NoPos is the zero value for Pos. It never refers to a real location.
+const NoPos Pos = 0
+
+func (p Pos) IsValid() bool { return p != NoPos }
+
+// Position is a fully resolved source location
+type Position struct {
+	Filename string // file name, if any
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1 (byte count)
+}
+
+func (pos Position) IsValid() bool { return pos.Line > 0 }
+
+// String renders the position as file:line:column, leaving out the file name
+// when there is none
+func (pos Position) String() string {
+
+	s := pos.Filename
+
+	if pos.IsValid() {
+
+		if s != "" {
+			s += ":"
+		}
+
+		s += fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+
+	}
+
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+
+}
+
+// File holds the line table for a single source file in a FileSet
+type File struct {
+	name  string
+	base  int
+	size  int
+	lines []int // byte offset of the first character of each line
+}
+
+func (f *File) Name() string { return f.name }
+func (f *File) Base() int    { return f.base }
+func (f *File) Size() int    { return f.size }
+
+// LineCount returns the number of lines seen so far
+func (f *File) LineCount() int { return len(f.lines) }
+
+// AddLine records the offset of the start of a new line. Offsets that are not
+// strictly increasing, or that fall outside the file, are ignored.
+func (f *File) AddLine(offset int) {
+
+	if n := len(f.lines); f.lines[n-1] < offset && offset <= f.size {
+		f.lines = append(f.lines, offset)
+	}
+
+}
+
+// Pos converts a byte offset in the file into a Pos
+func (f *File) Pos(offset int) Pos {
+
+	if offset < 0 {
+		offset = 0
+	} else if offset > f.size {
+		offset = f.size
+	}
+
+	return Pos(f.base + offset)
+
+}
+
+// Offset converts a Pos back into a byte offset in the file
+func (f *File) Offset(p Pos) int {
+	return int(p) - f.base
+}
+
+// Position resolves p into a file name, line, column and offset
+func (f *File) Position(p Pos) Position {
+
+	if !p.IsValid() {
+		return Position{}
+	}
+
+	offset := f.Offset(p)
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     i + 1,
+		Column:   offset - f.lines[i] + 1,
+	}
+
+}
+
+// FileSet is a registry of source files sharing a single Pos space
+type FileSet struct {
+	base  int
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// Base returns the Pos that will be given to the next added file
+func (s *FileSet) Base() int { return s.base }
+
+// AddFile registers a new file of the given size and returns it
+func (s *FileSet) AddFile(filename string, size int) *File {
+
+	f := &File{name: filename, base: s.base, size: size, lines: []int{0}}
+
+	// +1 so the EOF position of one file never collides with the next file
+	s.base += size + 1
+	s.files = append(s.files, f)
+
+	return f
+
+}
+
+// File returns the file containing p, or nil if there is none
+func (s *FileSet) File(p Pos) *File {
+
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+
+	if i < 0 {
+		return nil
+	}
+
+	if f := s.files[i]; int(p) <= f.base+f.size {
+		return f
+	}
+
+	return nil
+
+}
+
+// Position resolves p using whichever file in the set contains it
+func (s *FileSet) Position(p Pos) Position {
+
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+
+	return Position{}
+
+}
@@ -0,0 +1,38 @@
+package token
+
+import "testing"
+
+func TestFileSetPosition(t *testing.T) {
+
+	fset := NewFileSet()
+	a := fset.AddFile("a.mk", 10)
+	b := fset.AddFile("b.mk", 6)
+
+	a.AddLine(4)
+	b.AddLine(3)
+
+	tests := []struct {
+		pos      Pos
+		expected string
+	}{
+		{a.Pos(0), "a.mk:1:1"},
+		{a.Pos(5), "a.mk:2:2"},
+		{a.Pos(10), "a.mk:2:7"},
+		{b.Pos(0), "b.mk:1:1"},
+		{b.Pos(4), "b.mk:2:2"},
+		{NoPos, "-"},
+	}
+
+	for _, tt := range tests {
+
+		if got := fset.Position(tt.pos).String(); got != tt.expected {
+			t.Errorf("Wrong position for %d. Expected=%q. Got=%q", tt.pos, tt.expected, got)
+		}
+
+	}
+
+	if fset.File(b.Pos(2)) != b {
+		t.Errorf("fset.File returned the wrong file for a position in b.mk")
+	}
+
+}
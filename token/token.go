@@ -5,8 +5,19 @@ package token
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type     TokenType
+	Literal  string
+	Pos      Pos       //position of the first character of the token
+	End      Pos       //position immediately after the last character of the token
+	Leading  []Comment //comments before the token, only kept in lexer.KeepComments mode
+	Trailing []Comment //comments after the token on the same line, only kept in lexer.KeepComments mode
+}
+
+//Comment is a // or /* */ comment kept as trivia on a Token
+type Comment struct {
+	Text string //the comment including its markers
+	Pos  Pos
+	End  Pos
 }
 
 //Define our token types
@@ -17,6 +28,8 @@ const (
 	//Identifiers + Literals
 	IDENT  = "IDENT"  //add, foobar, x, y ...
 	INT    = "INT"    //Integer literal
+	FLOAT  = "FLOAT"  //Floating-point literal
+	RAT    = "RAT"    //Rational literal, a decimal number with an r suffix: 1r, 0.1r
 	STRING = "STRING" // String literal
 
 	//Operators
@@ -26,16 +39,44 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 
 	LT     = "<"
 	GT     = ">"
+	LT_EQ  = "<="
+	GT_EQ  = ">="
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND  = "&&"
+	OR   = "||"
+	PIPE = "|>"
+
+	QUESTION       = "?"
+	NULL_COALESCE  = "??"
+	OPTIONAL_CHAIN = "?."
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
+
+	BIT_AND = "&"
+	BIT_OR  = "|"
+	BIT_XOR = "^"
+	BIT_NOT = "~"
+	SHL     = "<<"
+	SHR     = ">>"
+
 	//Delimiters
-	COMMA = ","
-	SEMI  = ";"
-	COLON = ":"
+	COMMA    = ","
+	SEMI     = ";"
+	COLON    = ":"
+	DOT      = "."
+	ARROW    = "=>"
+	ELLIPSIS = "..."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -52,17 +93,29 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	FOR      = "FOR"
+	IN       = "IN"
+	MATCH    = "MATCH"
 )
 
 //Define language keywords/map them to their token type
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"for":      FOR,
+	"in":       IN,
+	"match":    MATCH,
 }
 
 /** Utility Functions **/